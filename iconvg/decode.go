@@ -0,0 +1,311 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// magic is the four bytes that every IconVG-encoded byte slice, as
+// produced by Encoder.Bytes, starts with: "IVG" followed by a format
+// version byte.
+var magic = [4]byte{'I', 'V', 'G', 0x00}
+
+// Destination is the set of drawing operations that an Encoder can record
+// and a Decoder can replay. Encoder implements Destination, so a Decoder
+// can drive an Encoder directly (e.g. to re-encode with a different
+// palette), as well as drive the rasterizer that Rasterize uses.
+type Destination interface {
+	Reset(m Metadata)
+
+	SetCReg(adj uint8, incremental bool, c Color)
+	SetNReg(adj uint8, incremental bool, f float32)
+
+	StartPath(adj uint8, x, y float32)
+	ClosePathEndPath()
+	ClosePathAbsMoveTo(x, y float32)
+	ClosePathRelMoveTo(x, y float32)
+
+	AbsHLineTo(x float32)
+	RelHLineTo(x float32)
+	AbsVLineTo(y float32)
+	RelVLineTo(y float32)
+	AbsLineTo(x, y float32)
+	RelLineTo(x, y float32)
+	AbsSmoothQuadTo(x, y float32)
+	RelSmoothQuadTo(x, y float32)
+	AbsQuadTo(x1, y1, x, y float32)
+	RelQuadTo(x1, y1, x, y float32)
+	AbsSmoothCubeTo(x2, y2, x, y float32)
+	RelSmoothCubeTo(x2, y2, x, y float32)
+	AbsCubeTo(x1, y1, x2, y2, x, y float32)
+	RelCubeTo(x1, y1, x2, y2, x, y float32)
+	AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32)
+	RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32)
+}
+
+// op identifies a single Destination call in the encoded byte stream.
+type op uint8
+
+const (
+	opSetCReg op = iota
+	opSetNReg
+	opStartPath
+	opClosePathEndPath
+	opClosePathAbsMoveTo
+	opClosePathRelMoveTo
+	opAbsHLineTo
+	opRelHLineTo
+	opAbsVLineTo
+	opRelVLineTo
+	opAbsLineTo
+	opRelLineTo
+	opAbsSmoothQuadTo
+	opRelSmoothQuadTo
+	opAbsQuadTo
+	opRelQuadTo
+	opAbsSmoothCubeTo
+	opRelSmoothCubeTo
+	opAbsCubeTo
+	opRelCubeTo
+	opAbsArcTo
+	opRelArcTo
+)
+
+// numArgs is the number of float32 arguments that follow each op in the
+// byte stream, not counting any leading adj byte, trailing flag bytes, or
+// (for opSetCReg) the 4 raw color bytes, which are decoded separately.
+var numArgs = [...]int{
+	opSetCReg:            0,
+	opSetNReg:            1,
+	opStartPath:          2,
+	opClosePathEndPath:   0,
+	opClosePathAbsMoveTo: 2,
+	opClosePathRelMoveTo: 2,
+	opAbsHLineTo:         1,
+	opRelHLineTo:         1,
+	opAbsVLineTo:         1,
+	opRelVLineTo:         1,
+	opAbsLineTo:          2,
+	opRelLineTo:          2,
+	opAbsSmoothQuadTo:    2,
+	opRelSmoothQuadTo:    2,
+	opAbsQuadTo:          4,
+	opRelQuadTo:          4,
+	opAbsSmoothCubeTo:    4,
+	opRelSmoothCubeTo:    4,
+	opAbsCubeTo:          6,
+	opRelCubeTo:          6,
+	opAbsArcTo:           5, // rx, ry, xAxisRotation, x, y (the two flag bits are separate bytes)
+	opRelArcTo:           5,
+}
+
+// Decoder walks IconVG-encoded bytes, as produced by Encoder.Bytes, and
+// drives a Destination with the same sequence of calls that produced them.
+// This lets a Destination other than Encoder -- typically the rasterizer
+// behind Rasterize -- consume already-encoded icons without going back
+// through SVG or any other source format.
+type Decoder struct {
+	data []byte
+}
+
+// NewDecoder returns a Decoder for the given encoded bytes.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Metadata decodes and returns just the data's Metadata, without replaying
+// its path operations into a Destination.
+func (d *Decoder) Metadata() (Metadata, error) {
+	r := d.data
+	if len(r) < len(magic) || [4]byte{r[0], r[1], r[2], r[3]} != magic {
+		return Metadata{}, fmt.Errorf("iconvg: bad magic bytes")
+	}
+	m, _, err := decodeMetadata(r[len(magic):])
+	return m, err
+}
+
+// Decode replays the decoder's bytes into dst, calling dst.Reset with the
+// decoded Metadata before any path operations.
+func (d *Decoder) Decode(dst Destination) error {
+	r := d.data
+	if len(r) < len(magic) || [4]byte{r[0], r[1], r[2], r[3]} != magic {
+		return fmt.Errorf("iconvg: bad magic bytes")
+	}
+	r = r[len(magic):]
+
+	m, r, err := decodeMetadata(r)
+	if err != nil {
+		return err
+	}
+	dst.Reset(m)
+
+	for len(r) > 0 {
+		o := op(r[0])
+		r = r[1:]
+		if int(o) >= len(numArgs) {
+			return fmt.Errorf("iconvg: bad opcode %d", o)
+		}
+
+		var adj uint8
+		switch o {
+		case opSetCReg, opSetNReg, opStartPath:
+			if len(r) < 1 {
+				return fmt.Errorf("iconvg: truncated stream")
+			}
+			adj, r = r[0], r[1:]
+			if adj >= numRegs {
+				return fmt.Errorf("iconvg: register index %d out of range [0, %d)", adj, numRegs)
+			}
+		}
+		var incremental bool
+		if o == opSetCReg || o == opSetNReg {
+			if len(r) < 1 {
+				return fmt.Errorf("iconvg: truncated stream")
+			}
+			incremental, r = r[0] != 0, r[1:]
+		}
+
+		var c Color
+		if o == opSetCReg {
+			if len(r) < 4 {
+				return fmt.Errorf("iconvg: truncated stream")
+			}
+			c = Color(rgbaFromBytes(r[:4]))
+			r = r[4:]
+		}
+
+		args := make([]float32, numArgs[o])
+		for i := range args {
+			f, rest, err := decodeFloat32(r)
+			if err != nil {
+				return err
+			}
+			args[i], r = f, rest
+		}
+
+		var flags [2]bool
+		if o == opAbsArcTo || o == opRelArcTo {
+			if len(r) < 2 {
+				return fmt.Errorf("iconvg: truncated stream")
+			}
+			flags[0], flags[1] = r[0] != 0, r[1] != 0
+			r = r[2:]
+		}
+
+		if err := replay(dst, o, adj, incremental, c, args, flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replay(dst Destination, o op, adj uint8, incremental bool, c Color, a []float32, flags [2]bool) error {
+	switch o {
+	case opSetCReg:
+		dst.SetCReg(adj, incremental, c)
+	case opSetNReg:
+		dst.SetNReg(adj, incremental, a[0])
+	case opStartPath:
+		dst.StartPath(adj, a[0], a[1])
+	case opClosePathEndPath:
+		dst.ClosePathEndPath()
+	case opClosePathAbsMoveTo:
+		dst.ClosePathAbsMoveTo(a[0], a[1])
+	case opClosePathRelMoveTo:
+		dst.ClosePathRelMoveTo(a[0], a[1])
+	case opAbsHLineTo:
+		dst.AbsHLineTo(a[0])
+	case opRelHLineTo:
+		dst.RelHLineTo(a[0])
+	case opAbsVLineTo:
+		dst.AbsVLineTo(a[0])
+	case opRelVLineTo:
+		dst.RelVLineTo(a[0])
+	case opAbsLineTo:
+		dst.AbsLineTo(a[0], a[1])
+	case opRelLineTo:
+		dst.RelLineTo(a[0], a[1])
+	case opAbsSmoothQuadTo:
+		dst.AbsSmoothQuadTo(a[0], a[1])
+	case opRelSmoothQuadTo:
+		dst.RelSmoothQuadTo(a[0], a[1])
+	case opAbsQuadTo:
+		dst.AbsQuadTo(a[0], a[1], a[2], a[3])
+	case opRelQuadTo:
+		dst.RelQuadTo(a[0], a[1], a[2], a[3])
+	case opAbsSmoothCubeTo:
+		dst.AbsSmoothCubeTo(a[0], a[1], a[2], a[3])
+	case opRelSmoothCubeTo:
+		dst.RelSmoothCubeTo(a[0], a[1], a[2], a[3])
+	case opAbsCubeTo:
+		dst.AbsCubeTo(a[0], a[1], a[2], a[3], a[4], a[5])
+	case opRelCubeTo:
+		dst.RelCubeTo(a[0], a[1], a[2], a[3], a[4], a[5])
+	case opAbsArcTo:
+		dst.AbsArcTo(a[0], a[1], a[2], flags[0], flags[1], a[3], a[4])
+	case opRelArcTo:
+		dst.RelArcTo(a[0], a[1], a[2], flags[0], flags[1], a[3], a[4])
+	default:
+		return fmt.Errorf("iconvg: unreplayable opcode %d", o)
+	}
+	return nil
+}
+
+// decodeMetadata decodes the Metadata section that immediately follows the
+// magic bytes -- a fixed-size ViewBox and 64-entry Palette, then a 4-byte
+// length and that many bytes of TLV chunks (see decodeMetadataChunks) --
+// returning the remaining, unconsumed bytes.
+func decodeMetadata(r []byte) (Metadata, []byte, error) {
+	var m Metadata
+	coords := make([]float32, 4)
+	for i := range coords {
+		f, rest, err := decodeFloat32(r)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("iconvg: bad metadata: %v", err)
+		}
+		coords[i], r = f, rest
+	}
+	m.ViewBox.Min[0], m.ViewBox.Min[1] = coords[0], coords[1]
+	m.ViewBox.Max[0], m.ViewBox.Max[1] = coords[2], coords[3]
+
+	for i := range m.Palette {
+		if len(r) < 4 {
+			return Metadata{}, nil, fmt.Errorf("iconvg: truncated palette")
+		}
+		m.Palette[i] = rgbaFromBytes(r[:4])
+		r = r[4:]
+	}
+
+	if len(r) < 4 {
+		return Metadata{}, nil, fmt.Errorf("iconvg: truncated metadata chunk section")
+	}
+	chunksLen := binary.LittleEndian.Uint32(r[:4])
+	r = r[4:]
+	if uint32(len(r)) < chunksLen {
+		return Metadata{}, nil, fmt.Errorf("iconvg: truncated metadata chunk section")
+	}
+	if err := decodeMetadataChunks(r[:chunksLen], &m); err != nil {
+		return Metadata{}, nil, err
+	}
+	r = r[chunksLen:]
+
+	return m, r, nil
+}
+
+func decodeFloat32(r []byte) (float32, []byte, error) {
+	if len(r) < 4 {
+		return 0, nil, fmt.Errorf("iconvg: truncated stream")
+	}
+	bits := binary.LittleEndian.Uint32(r)
+	return math.Float32frombits(bits), r[4:], nil
+}
+
+func rgbaFromBytes(b []byte) color.RGBA {
+	return color.RGBA{R: b[0], G: b[1], B: b[2], A: b[3]}
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+)
+
+// Encoder builds up an IconVG-encoded byte slice by recording Destination
+// calls, the same set of calls a Decoder replays from already-encoded
+// bytes. The zero Encoder is not ready to use; call Reset first.
+type Encoder struct {
+	metadata Metadata
+	ops      []byte
+}
+
+// Reset discards any previously recorded ops and starts a new icon with
+// the given Metadata.
+func (e *Encoder) Reset(m Metadata) {
+	e.metadata = m
+	e.ops = e.ops[:0]
+}
+
+// Bytes returns the encoded icon: the magic bytes, followed by its
+// Metadata, followed by every op recorded since the last Reset.
+func (e *Encoder) Bytes() ([]byte, error) {
+	b := append([]byte(nil), magic[:]...)
+
+	for _, f := range []float32{
+		e.metadata.ViewBox.Min[0], e.metadata.ViewBox.Min[1],
+		e.metadata.ViewBox.Max[0], e.metadata.ViewBox.Max[1],
+	} {
+		b = appendFloat32(b, f)
+	}
+	for _, c := range e.metadata.Palette {
+		b = append(b, c.R, c.G, c.B, c.A)
+	}
+
+	chunks := EncodeMetadataChunks(e.metadata)
+	var chunksLen [4]byte
+	binary.LittleEndian.PutUint32(chunksLen[:], uint32(len(chunks)))
+	b = append(b, chunksLen[:]...)
+	b = append(b, chunks...)
+
+	b = append(b, e.ops...)
+	return b, nil
+}
+
+func appendFloat32(b []byte, f float32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], math.Float32bits(f))
+	return append(b, buf[:]...)
+}
+
+func (e *Encoder) appendOp(o op, adj uint8, withAdj bool) {
+	e.ops = append(e.ops, byte(o))
+	if withAdj {
+		e.ops = append(e.ops, adj)
+	}
+}
+
+func (e *Encoder) appendArgs(args ...float32) {
+	for _, f := range args {
+		e.ops = appendFloat32(e.ops, f)
+	}
+}
+
+func (e *Encoder) appendFlags(flags ...bool) {
+	for _, f := range flags {
+		e.ops = append(e.ops, boolByte(f))
+	}
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (e *Encoder) SetCReg(adj uint8, incremental bool, c Color) {
+	e.appendOp(opSetCReg, adj, true)
+	e.ops = append(e.ops, boolByte(incremental))
+	e.ops = append(e.ops, c.R, c.G, c.B, c.A)
+}
+
+func (e *Encoder) SetNReg(adj uint8, incremental bool, f float32) {
+	e.appendOp(opSetNReg, adj, true)
+	e.ops = append(e.ops, boolByte(incremental))
+	e.appendArgs(f)
+}
+
+func (e *Encoder) StartPath(adj uint8, x, y float32) {
+	e.appendOp(opStartPath, adj, true)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) ClosePathEndPath() {
+	e.appendOp(opClosePathEndPath, 0, false)
+}
+
+func (e *Encoder) ClosePathAbsMoveTo(x, y float32) {
+	e.appendOp(opClosePathAbsMoveTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) ClosePathRelMoveTo(x, y float32) {
+	e.appendOp(opClosePathRelMoveTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) AbsHLineTo(x float32) {
+	e.appendOp(opAbsHLineTo, 0, false)
+	e.appendArgs(x)
+}
+
+func (e *Encoder) RelHLineTo(x float32) {
+	e.appendOp(opRelHLineTo, 0, false)
+	e.appendArgs(x)
+}
+
+func (e *Encoder) AbsVLineTo(y float32) {
+	e.appendOp(opAbsVLineTo, 0, false)
+	e.appendArgs(y)
+}
+
+func (e *Encoder) RelVLineTo(y float32) {
+	e.appendOp(opRelVLineTo, 0, false)
+	e.appendArgs(y)
+}
+
+func (e *Encoder) AbsLineTo(x, y float32) {
+	e.appendOp(opAbsLineTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) RelLineTo(x, y float32) {
+	e.appendOp(opRelLineTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) AbsSmoothQuadTo(x, y float32) {
+	e.appendOp(opAbsSmoothQuadTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) RelSmoothQuadTo(x, y float32) {
+	e.appendOp(opRelSmoothQuadTo, 0, false)
+	e.appendArgs(x, y)
+}
+
+func (e *Encoder) AbsQuadTo(x1, y1, x, y float32) {
+	e.appendOp(opAbsQuadTo, 0, false)
+	e.appendArgs(x1, y1, x, y)
+}
+
+func (e *Encoder) RelQuadTo(x1, y1, x, y float32) {
+	e.appendOp(opRelQuadTo, 0, false)
+	e.appendArgs(x1, y1, x, y)
+}
+
+func (e *Encoder) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	e.appendOp(opAbsSmoothCubeTo, 0, false)
+	e.appendArgs(x2, y2, x, y)
+}
+
+func (e *Encoder) RelSmoothCubeTo(x2, y2, x, y float32) {
+	e.appendOp(opRelSmoothCubeTo, 0, false)
+	e.appendArgs(x2, y2, x, y)
+}
+
+func (e *Encoder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	e.appendOp(opAbsCubeTo, 0, false)
+	e.appendArgs(x1, y1, x2, y2, x, y)
+}
+
+func (e *Encoder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	e.appendOp(opRelCubeTo, 0, false)
+	e.appendArgs(x1, y1, x2, y2, x, y)
+}
+
+func (e *Encoder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	e.appendOp(opAbsArcTo, 0, false)
+	e.appendArgs(rx, ry, xAxisRotation, x, y)
+	e.appendFlags(largeArc, sweep)
+}
+
+func (e *Encoder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	e.appendOp(opRelArcTo, 0, false)
+	e.appendArgs(rx, ry, xAxisRotation, x, y)
+	e.appendFlags(largeArc, sweep)
+}
+
+// BlendColor linearly interpolates from c0 to c1, weighted aa/0xff toward
+// c1, e.g. aa==0 is c0 and aa==0xff is c1. It's the usual way to derive a
+// translucent CREG color from a single opacity value, as
+// materialdesign/icons/gen.go and iconvg/svg do for fill-opacity.
+func BlendColor(aa uint8, c0, c1 color.RGBA) Color {
+	w := float32(aa) / 0xff
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float32(a) + (float32(b)-float32(a))*w + 0.5)
+	}
+	return Color(color.RGBA{
+		R: lerp(c0.R, c1.R),
+		G: lerp(c0.G, c1.G),
+		B: lerp(c0.B, c1.B),
+		A: lerp(c0.A, c1.A),
+	})
+}
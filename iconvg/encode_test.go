@@ -0,0 +1,173 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// recorder is a Destination that records each call it receives as a
+// string, so two independently driven recorders (or a recorder and a
+// Decoder-replayed one) can be compared call-for-call.
+type recorder struct {
+	meta  Metadata
+	calls []string
+}
+
+func (r *recorder) record(format string, args ...interface{}) {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Reset(m Metadata) { r.meta = m }
+
+func (r *recorder) SetCReg(adj uint8, incremental bool, c Color) {
+	r.record("SetCReg(%d, %v, %v)", adj, incremental, c)
+}
+func (r *recorder) SetNReg(adj uint8, incremental bool, f float32) {
+	r.record("SetNReg(%d, %v, %v)", adj, incremental, f)
+}
+func (r *recorder) StartPath(adj uint8, x, y float32) {
+	r.record("StartPath(%d, %v, %v)", adj, x, y)
+}
+func (r *recorder) ClosePathEndPath() { r.record("ClosePathEndPath()") }
+func (r *recorder) ClosePathAbsMoveTo(x, y float32) {
+	r.record("ClosePathAbsMoveTo(%v, %v)", x, y)
+}
+func (r *recorder) ClosePathRelMoveTo(x, y float32) {
+	r.record("ClosePathRelMoveTo(%v, %v)", x, y)
+}
+func (r *recorder) AbsHLineTo(x float32)   { r.record("AbsHLineTo(%v)", x) }
+func (r *recorder) RelHLineTo(x float32)   { r.record("RelHLineTo(%v)", x) }
+func (r *recorder) AbsVLineTo(y float32)   { r.record("AbsVLineTo(%v)", y) }
+func (r *recorder) RelVLineTo(y float32)   { r.record("RelVLineTo(%v)", y) }
+func (r *recorder) AbsLineTo(x, y float32) { r.record("AbsLineTo(%v, %v)", x, y) }
+func (r *recorder) RelLineTo(x, y float32) { r.record("RelLineTo(%v, %v)", x, y) }
+func (r *recorder) AbsSmoothQuadTo(x, y float32) {
+	r.record("AbsSmoothQuadTo(%v, %v)", x, y)
+}
+func (r *recorder) RelSmoothQuadTo(x, y float32) {
+	r.record("RelSmoothQuadTo(%v, %v)", x, y)
+}
+func (r *recorder) AbsQuadTo(x1, y1, x, y float32) {
+	r.record("AbsQuadTo(%v, %v, %v, %v)", x1, y1, x, y)
+}
+func (r *recorder) RelQuadTo(x1, y1, x, y float32) {
+	r.record("RelQuadTo(%v, %v, %v, %v)", x1, y1, x, y)
+}
+func (r *recorder) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	r.record("AbsSmoothCubeTo(%v, %v, %v, %v)", x2, y2, x, y)
+}
+func (r *recorder) RelSmoothCubeTo(x2, y2, x, y float32) {
+	r.record("RelSmoothCubeTo(%v, %v, %v, %v)", x2, y2, x, y)
+}
+func (r *recorder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.record("AbsCubeTo(%v, %v, %v, %v, %v, %v)", x1, y1, x2, y2, x, y)
+}
+func (r *recorder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.record("RelCubeTo(%v, %v, %v, %v, %v, %v)", x1, y1, x2, y2, x, y)
+}
+func (r *recorder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.record("AbsArcTo(%v, %v, %v, %v, %v, %v, %v)", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+func (r *recorder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.record("RelArcTo(%v, %v, %v, %v, %v, %v, %v)", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+// drive feeds a fixed, representative sequence of calls into dst: every op
+// that carries an adj, incremental, color or arc-flag byte, plus a sample
+// of the plain-argument ops (not every op kind).
+func drive(dst Destination, meta Metadata) {
+	dst.Reset(meta)
+	dst.SetCReg(1, false, Color{R: 0x11, G: 0x22, B: 0x33, A: 0xff})
+	dst.SetNReg(2, true, 0.5)
+	dst.StartPath(0, -10, -10)
+	dst.AbsLineTo(10, -10)
+	dst.RelHLineTo(1)
+	dst.AbsVLineTo(2)
+	dst.AbsQuadTo(1, 2, 3, 4)
+	dst.RelSmoothCubeTo(1, 2, 3, 4)
+	dst.AbsArcTo(5, 3, 45, true, false, 10, 10)
+	dst.ClosePathAbsMoveTo(-10, -10)
+	dst.ClosePathEndPath()
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	meta := Metadata{
+		ViewBox: Rectangle{Min: [2]float32{-24, -24}, Max: [2]float32{24, 24}},
+		Palette: DefaultPalette,
+		ColorSlots: []ColorSlot{
+			{Name: "fg", Register: 0, Default: color.RGBA{A: 0xff}},
+		},
+		SuggestedViewport: Viewport{Width: 24, Height: 24},
+		RTLMirror:         true,
+	}
+
+	want := &recorder{}
+	drive(want, meta)
+
+	var enc Encoder
+	drive(&enc, meta)
+	data, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	got := &recorder{}
+	if err := NewDecoder(data).Decode(got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.meta, want.meta) {
+		t.Errorf("metadata mismatch:\ngot  %+v\nwant %+v", got.meta, want.meta)
+	}
+	if !reflect.DeepEqual(got.calls, want.calls) {
+		t.Errorf("calls mismatch:\ngot  %v\nwant %v", got.calls, want.calls)
+	}
+}
+
+func TestDecoderMetadataOnly(t *testing.T) {
+	meta := Metadata{
+		ViewBox:   Rectangle{Min: [2]float32{-1, -1}, Max: [2]float32{1, 1}},
+		Palette:   DefaultPalette,
+		RTLMirror: true,
+	}
+	var enc Encoder
+	enc.Reset(meta)
+	enc.ClosePathEndPath()
+	data, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	got, err := NewDecoder(data).Metadata()
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if !reflect.DeepEqual(got, meta) {
+		t.Errorf("got %+v, want %+v", got, meta)
+	}
+}
+
+// TestDecodeOutOfRangeRegister checks that an out-of-range register index
+// (cReg/nReg only have 64 slots) is rejected as a decode error rather than
+// reaching a Destination and panicking there, since Destination's methods
+// have no way to report an error of their own.
+func TestDecodeOutOfRangeRegister(t *testing.T) {
+	var enc Encoder
+	enc.Reset(Metadata{Palette: DefaultPalette})
+	enc.StartPath(64, 0, 0)
+	enc.ClosePathEndPath()
+	data, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	if err := NewDecoder(data).Decode(&recorder{}); err == nil {
+		t.Fatal("Decode: got nil error, want an out-of-range register error")
+	}
+}
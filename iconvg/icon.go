@@ -0,0 +1,70 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Icon pairs an icon's encoded bytes with its decoded Metadata, and lets a
+// caller recolor its named ColorSlots before rasterizing -- e.g.
+//
+//	home, err := iconvg.NewIcon(icons.ActionHome)
+//	home.SetSlot("fg", color.Black)
+//	home.Rasterize(dst, r, nil)
+//
+// Most of this package's functions work directly on raw []byte, which is
+// enough for a one-off render; Icon is a convenience for the common case
+// of rendering the same icon repeatedly under different themes.
+type Icon struct {
+	Data      []byte
+	Metadata  Metadata
+	overrides map[uint8]color.RGBA
+}
+
+// NewIcon decodes data's Metadata and returns an Icon wrapping it.
+func NewIcon(data []byte) (*Icon, error) {
+	m, err := NewDecoder(data).Metadata()
+	if err != nil {
+		return nil, err
+	}
+	return &Icon{Data: data, Metadata: m}, nil
+}
+
+// SetSlot overrides the named ColorSlot's register to c. It's a no-op if
+// the icon has no slot with that name.
+func (ic *Icon) SetSlot(name string, c color.Color) {
+	for _, cs := range ic.Metadata.ColorSlots {
+		if cs.Name == name {
+			if ic.overrides == nil {
+				ic.overrides = map[uint8]color.RGBA{}
+			}
+			ic.overrides[cs.Register] = color.RGBAModel.Convert(c).(color.RGBA)
+			return
+		}
+	}
+}
+
+// Rasterize draws the icon into dst, applying any SetSlot overrides on
+// top of opts.ColorRegisters.
+func (ic *Icon) Rasterize(dst draw.Image, r image.Rectangle, opts *RasterOptions) error {
+	o := RasterOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if len(ic.overrides) > 0 {
+		merged := make(map[uint8]color.RGBA, len(o.ColorRegisters)+len(ic.overrides))
+		for k, v := range o.ColorRegisters {
+			merged[k] = v
+		}
+		for k, v := range ic.overrides {
+			merged[k] = v
+		}
+		o.ColorRegisters = merged
+	}
+	return Rasterize(dst, r, ic.Data, &o)
+}
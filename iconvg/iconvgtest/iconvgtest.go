@@ -0,0 +1,326 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package iconvgtest rasterizes IconVG icons and compares them against
+// golden PNG images, giving package iconvg and its callers a real
+// regression signal for changes to the encoder, rasterizer or SVG parser,
+// instead of just trusting that a change compiled and gofmt'd cleanly.
+package iconvgtest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/glycerine/shiny/iconvg"
+)
+
+// Golden is one icon to rasterize and compare against a reference PNG.
+type Golden struct {
+	// Name identifies the icon and is used, together with the pixel size,
+	// to build the golden PNG's file name: "<GoldenDir>/<Name>_<size>px.png".
+	Name string
+	// Data is the icon's encoded IconVG bytes.
+	Data []byte
+}
+
+// Options configures a Run.
+type Options struct {
+	// Sizes are the pixel widths/heights to rasterize and compare at.
+	// Defaults to {24, 48}, matching the two PNG sizes gen.go already
+	// reads byte counts from.
+	Sizes []int
+
+	// Threshold is the maximum allowed mean perceptual error, in
+	// linear-light sRGB, before a (icon, size) pair is reported as a
+	// failure. Defaults to 0.02.
+	Threshold float64
+
+	// GaussianBlurRadius, in pixels, is applied to both the rendered and
+	// golden images before they're compared, so that anti-aliasing
+	// nudging an edge by a pixel isn't reported as a difference. Defaults
+	// to 1.
+	GaussianBlurRadius float64
+
+	// GoldenDir holds the "<name>_<size>px.png" reference images. A
+	// missing golden file skips that pair rather than failing it: the
+	// PNGs are exported from a local material-design-icons checkout (see
+	// materialdesign/icons/gen.go's -mdicons flag) that isn't vendored
+	// into this repository.
+	GoldenDir string
+
+	// ReportPath, if non-empty, is where an HTML report ranking every
+	// compared pair by score, with inline diff images for the worst
+	// offenders, is written after the run -- win or lose.
+	ReportPath string
+}
+
+// Run rasterizes every Golden at every configured size, compares it
+// against GoldenDir's reference PNG, and calls t.Errorf for any pair
+// whose score exceeds Threshold.
+func Run(t *testing.T, goldens []Golden, opts Options) {
+	t.Helper()
+	sizes := opts.Sizes
+	if len(sizes) == 0 {
+		sizes = []int{24, 48}
+	}
+	threshold := opts.Threshold
+	if threshold == 0 {
+		threshold = 0.02
+	}
+	radius := opts.GaussianBlurRadius
+	if radius == 0 {
+		radius = 1
+	}
+
+	var results []result
+	for _, g := range goldens {
+		for _, size := range sizes {
+			r := result{name: g.Name, size: size}
+			wantPath := filepath.Join(opts.GoldenDir, fmt.Sprintf("%s_%dpx.png", g.Name, size))
+			want, err := loadPNG(wantPath)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				r.err = err
+				results = append(results, r)
+				continue
+			}
+			got, err := rasterize(g.Data, size)
+			if err != nil {
+				r.err = err
+				results = append(results, r)
+				continue
+			}
+			r.score, r.diff = compare(got, want, radius)
+			results = append(results, r)
+		}
+	}
+
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			t.Errorf("%s @ %dpx: %v", r.name, r.size, r.err)
+		case r.score > threshold:
+			t.Errorf("%s @ %dpx: perceptual error %.4f exceeds threshold %.4f", r.name, r.size, r.score, threshold)
+		}
+	}
+
+	if opts.ReportPath != "" {
+		if err := writeReport(opts.ReportPath, results, threshold); err != nil {
+			t.Errorf("iconvgtest: writing report: %v", err)
+		}
+	}
+}
+
+// result is one compared (icon, size) pair.
+type result struct {
+	name  string
+	size  int
+	score float64
+	diff  *image.RGBA
+	err   error
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func rasterize(data []byte, size int) (image.Image, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if err := iconvg.Rasterize(dst, dst.Bounds(), data, &iconvg.RasterOptions{AAKind: iconvg.AAGammaCorrect}); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// compare returns a and b's mean per-pixel L2 distance in linear-light
+// sRGB, after blurring both by radius pixels, along with a diff image
+// (brighter pixels mean a larger per-pixel difference).
+func compare(a, b image.Image, radius float64) (float64, *image.RGBA) {
+	bounds := b.Bounds()
+	la := blur(toLinear(a, bounds), radius)
+	lb := blur(toLinear(b, bounds), radius)
+
+	diff := image.NewRGBA(bounds)
+	var sum float64
+	n := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pa := la[y][x]
+			pb := lb[y][x]
+			d := 0.0
+			for c := 0; c < 4; c++ {
+				dc := pa[c] - pb[c]
+				d += dc * dc
+			}
+			d = math.Sqrt(d / 4)
+			sum += d
+			n++
+			v := uint8(math.Min(1, d*4) * 0xff)
+			diff.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+	return sum / float64(n), diff
+}
+
+// toLinear converts img to a [y][x][4]float64 grid of linear-light,
+// non-alpha-premultiplied RGBA samples in [0, 1].
+func toLinear(img image.Image, bounds image.Rectangle) [][][4]float64 {
+	grid := make([][][4]float64, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := make([][4]float64, bounds.Dx())
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				row[x-bounds.Min.X] = [4]float64{0, 0, 0, 0}
+				continue
+			}
+			row[x-bounds.Min.X] = [4]float64{
+				toLinear1(float64(r) / float64(a)),
+				toLinear1(float64(g) / float64(a)),
+				toLinear1(float64(b) / float64(a)),
+				float64(a) / 0xffff,
+			}
+		}
+		grid[y-bounds.Min.Y] = row
+	}
+	return grid
+}
+
+func toLinear1(srgb float64) float64 {
+	if srgb <= 0.04045 {
+		return srgb / 12.92
+	}
+	return math.Pow((srgb+0.055)/1.055, 2.4)
+}
+
+// blur returns a copy of grid with a small separable Gaussian blur
+// applied (grid itself is left unchanged), so a 1-pixel
+// anti-alias shift between the rendered and golden images contributes
+// little to the comparison.
+func blur(grid [][][4]float64, radius float64) [][][4]float64 {
+	if radius <= 0 {
+		return grid
+	}
+	kernel := gaussianKernel(radius)
+	return convolve(convolve(grid, kernel, true), kernel, false)
+}
+
+func gaussianKernel(radius float64) []float64 {
+	n := int(math.Ceil(radius*3)) * 2
+	if n < 1 {
+		n = 1
+	}
+	k := make([]float64, 2*n+1)
+	sum := 0.0
+	for i := range k {
+		d := float64(i - n)
+		v := math.Exp(-(d * d) / (2 * radius * radius))
+		k[i] = v
+		sum += v
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	return k
+}
+
+func convolve(grid [][][4]float64, kernel []float64, horizontal bool) [][][4]float64 {
+	h, w := len(grid), len(grid[0])
+	out := make([][][4]float64, h)
+	for y := range out {
+		out[y] = make([][4]float64, w)
+	}
+	half := len(kernel) / 2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]float64
+			for i, k := range kernel {
+				sx, sy := x, y
+				if horizontal {
+					sx = clampInt(x+i-half, 0, w-1)
+				} else {
+					sy = clampInt(y+i-half, 0, h-1)
+				}
+				p := grid[sy][sx]
+				for c := 0; c < 4; c++ {
+					sum[c] += p[c] * k
+				}
+			}
+			out[y][x] = sum
+		}
+	}
+	return out
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func writeReport(path string, results []result, threshold float64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	sorted := append([]result(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].score > sorted[j].score })
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>IconVG golden report</title>\n")
+	fmt.Fprintf(f, "<p>threshold: %.4f, %d pairs compared</p>\n<table border=1 cellpadding=4>\n", threshold, len(sorted))
+	fmt.Fprintf(f, "<tr><th>icon</th><th>size</th><th>score</th><th>diff</th></tr>\n")
+	for i, r := range sorted {
+		if r.err != nil {
+			fmt.Fprintf(f, "<tr><td>%s</td><td>%d</td><td colspan=2>error: %v</td></tr>\n", r.name, r.size, r.err)
+			continue
+		}
+		status := "ok"
+		if r.score > threshold {
+			status = "FAIL"
+		}
+		diffName := fmt.Sprintf("diff-%d.png", i)
+		if r.diff != nil {
+			if err := writePNG(filepath.Join(filepath.Dir(path), diffName), r.diff); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(f, "<tr><td>%s</td><td>%d</td><td>%.4f (%s)</td><td><img src=%q width=48></td></tr>\n",
+			r.name, r.size, r.score, status, diffName)
+	}
+	fmt.Fprintf(f, "</table>\n")
+	return nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
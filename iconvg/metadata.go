@@ -0,0 +1,104 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Metadata chunk IDs. Each ID identifies the payload format of one TLV
+// (tag-length-value) chunk in the metadata section that follows an icon's
+// ViewBox and Palette. A decoder that doesn't recognize a chunk ID skips
+// it by its declared length rather than failing, so new chunk kinds can
+// be added -- and old ones dropped -- without breaking decoders built
+// against an earlier version of this package.
+const (
+	chunkColorSlot         = 0x01
+	chunkSuggestedViewport = 0x02
+	chunkRTLMirror         = 0x03
+)
+
+// EncodeMetadataChunks serializes m's ColorSlots, SuggestedViewport and
+// RTLMirror fields (but not ViewBox or Palette, which have their own
+// fixed-size encoding) as a sequence of versioned TLV chunks.
+func EncodeMetadataChunks(m Metadata) []byte {
+	var b []byte
+	for _, cs := range m.ColorSlots {
+		payload := make([]byte, 0, 1+len(cs.Name)+1+4)
+		payload = append(payload, byte(len(cs.Name)))
+		payload = append(payload, cs.Name...)
+		payload = append(payload, cs.Register)
+		payload = append(payload, cs.Default.R, cs.Default.G, cs.Default.B, cs.Default.A)
+		b = appendChunk(b, chunkColorSlot, payload)
+	}
+	if m.SuggestedViewport != (Viewport{}) {
+		payload := make([]byte, 4)
+		binary.LittleEndian.PutUint16(payload[0:2], uint16(m.SuggestedViewport.Width))
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(m.SuggestedViewport.Height))
+		b = appendChunk(b, chunkSuggestedViewport, payload)
+	}
+	if m.RTLMirror {
+		b = appendChunk(b, chunkRTLMirror, []byte{1})
+	}
+	return b
+}
+
+func appendChunk(b []byte, id byte, payload []byte) []byte {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(payload)))
+	b = append(b, id)
+	b = append(b, length[:]...)
+	b = append(b, payload...)
+	return b
+}
+
+// decodeMetadataChunks parses the TLV chunk section that r holds in its
+// entirety, populating the non-fixed-size fields of m.
+func decodeMetadataChunks(r []byte, m *Metadata) error {
+	for len(r) > 0 {
+		if len(r) < 5 {
+			return fmt.Errorf("iconvg: truncated metadata chunk")
+		}
+		id := r[0]
+		length := binary.LittleEndian.Uint32(r[1:5])
+		r = r[5:]
+		if uint32(len(r)) < length {
+			return fmt.Errorf("iconvg: truncated metadata chunk")
+		}
+		payload := r[:length]
+		r = r[length:]
+
+		switch id {
+		case chunkColorSlot:
+			if len(payload) < 1 {
+				return fmt.Errorf("iconvg: malformed color slot chunk")
+			}
+			nameLen := int(payload[0])
+			if len(payload) < 1+nameLen+1+4 {
+				return fmt.Errorf("iconvg: malformed color slot chunk")
+			}
+			name := string(payload[1 : 1+nameLen])
+			register := payload[1+nameLen]
+			c := rgbaFromBytes(payload[1+nameLen+1 : 1+nameLen+1+4])
+			m.ColorSlots = append(m.ColorSlots, ColorSlot{Name: name, Register: register, Default: c})
+		case chunkSuggestedViewport:
+			if len(payload) < 4 {
+				return fmt.Errorf("iconvg: malformed suggested-viewport chunk")
+			}
+			m.SuggestedViewport.Width = int(binary.LittleEndian.Uint16(payload[0:2]))
+			m.SuggestedViewport.Height = int(binary.LittleEndian.Uint16(payload[2:4]))
+		case chunkRTLMirror:
+			if len(payload) < 1 {
+				return fmt.Errorf("iconvg: malformed RTL-mirror chunk")
+			}
+			m.RTLMirror = payload[0] != 0
+		default:
+			// Unknown chunk kind: skip it. This is what makes the chunk
+			// format forward-compatible.
+		}
+	}
+	return nil
+}
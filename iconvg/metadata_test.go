@@ -0,0 +1,58 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataChunksRoundTrip(t *testing.T) {
+	want := Metadata{
+		ColorSlots: []ColorSlot{
+			{Name: "fg", Register: 0, Default: color.RGBA{A: 0xff}},
+			{Name: "accent", Register: 3, Default: color.RGBA{R: 0xff, A: 0xff}},
+		},
+		SuggestedViewport: Viewport{Width: 48, Height: 48},
+		RTLMirror:         true,
+	}
+
+	encoded := EncodeMetadataChunks(want)
+
+	var got Metadata
+	if err := decodeMetadataChunks(encoded, &got); err != nil {
+		t.Fatalf("decodeMetadataChunks: %v", err)
+	}
+	if !reflect.DeepEqual(got.ColorSlots, want.ColorSlots) {
+		t.Errorf("ColorSlots: got %+v, want %+v", got.ColorSlots, want.ColorSlots)
+	}
+	if got.SuggestedViewport != want.SuggestedViewport {
+		t.Errorf("SuggestedViewport: got %+v, want %+v", got.SuggestedViewport, want.SuggestedViewport)
+	}
+	if got.RTLMirror != want.RTLMirror {
+		t.Errorf("RTLMirror: got %v, want %v", got.RTLMirror, want.RTLMirror)
+	}
+}
+
+func TestMetadataChunksSkipUnknown(t *testing.T) {
+	want := Metadata{RTLMirror: true}
+	encoded := EncodeMetadataChunks(want)
+
+	// Splice an unrecognized chunk kind in front of the known ones; a
+	// decoder built against an earlier version of this package (i.e. one
+	// that doesn't know about this ID) must skip it by length rather than
+	// fail, which is the whole point of the chunk format being versioned.
+	unknown := appendChunk(nil, 0x7f, []byte{1, 2, 3})
+	encoded = append(unknown, encoded...)
+
+	var got Metadata
+	if err := decodeMetadataChunks(encoded, &got); err != nil {
+		t.Fatalf("decodeMetadataChunks: %v", err)
+	}
+	if got.RTLMirror != true {
+		t.Errorf("RTLMirror: got %v, want true", got.RTLMirror)
+	}
+}
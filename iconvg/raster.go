@@ -0,0 +1,576 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/math/f32"
+)
+
+// AAKind selects how Rasterize anti-aliases path edges.
+type AAKind int
+
+const (
+	// AANone disables anti-aliasing: each pixel is either fully covered or
+	// not, based on its center.
+	AANone AAKind = iota
+	// AA2x2 supersamples each pixel on a 2x2 grid.
+	AA2x2
+	// AA4x4 supersamples each pixel on a 4x4 grid.
+	AA4x4
+	// AAGammaCorrect is like AA4x4, but blends coverage in linear light
+	// instead of directly in sRGB space, which avoids the darkened-looking
+	// edges that naive alpha blending of sRGB colors produces.
+	AAGammaCorrect
+)
+
+func (k AAKind) samplesPerAxis() int {
+	switch k {
+	case AA2x2:
+		return 2
+	case AA4x4, AAGammaCorrect:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// RasterOptions configures Rasterize.
+type RasterOptions struct {
+	// Palette overrides the Metadata.Palette recorded in the icon being
+	// rasterized. A zero value keeps the icon's own palette.
+	Palette *Palette
+
+	// ColorRegisters, if non-nil, overrides the named CREG slots (as set
+	// by SetCReg while decoding) before rendering -- e.g. to re-theme an
+	// icon's "fg" color without re-encoding it.
+	ColorRegisters map[uint8]color.RGBA
+
+	// NumberRegisters, if non-nil, overrides the named NREG slots.
+	NumberRegisters map[uint8]float32
+
+	// AAKind selects the anti-aliasing method. The zero value is AANone.
+	AAKind AAKind
+}
+
+// Rasterize decodes the IconVG-encoded data and draws it into dst, scaled
+// and translated so that the icon's ViewBox fills r.
+func Rasterize(dst draw.Image, r image.Rectangle, data []byte, opts *RasterOptions) error {
+	rz := &rasterizer{dst: dst, rect: r}
+	if opts != nil {
+		rz.opts = *opts
+	}
+	if err := NewDecoder(data).Decode(rz); err != nil {
+		return err
+	}
+	rz.done()
+	return nil
+}
+
+// rasterizer implements Destination by flattening every path into line
+// segments in device space, then filling each closed subpath with its
+// resolved color using a scanline, nonzero-winding-rule fill.
+type rasterizer struct {
+	dst  draw.Image
+	rect image.Rectangle
+	opts RasterOptions
+
+	m       f32.Aff3 // icon vector space -> device pixel space
+	palette Palette
+	cReg    [numRegs]color.RGBA
+	nReg    [numRegs]float32
+
+	subpaths []subpath
+	cur      subpath
+	cx, cy   float32 // current point, in device space
+
+	// lastQCtrl and lastCCtrl are the control point (in vector space) of
+	// the most recent quadratic or cubic curve op, used to reflect a
+	// following "smooth" (T or S) curve's implicit control point. lastOp
+	// tracks which kind was last seen, since a smooth curve only reflects
+	// when it immediately follows a curve of the same family.
+	lastQCtrl, lastCCtrl f32.Vec2
+	lastOp               byte // 'Q', 'C', or 0
+}
+
+type subpath struct {
+	fill   color.RGBA
+	points []f32.Vec2
+}
+
+func (z *rasterizer) Reset(meta Metadata) {
+	z.palette = meta.Palette
+	if z.opts.Palette != nil {
+		z.palette = *z.opts.Palette
+	}
+	for i, c := range z.palette {
+		z.cReg[i] = c
+	}
+	for adj, c := range z.opts.ColorRegisters {
+		z.cReg[adj] = c
+	}
+	for adj, f := range z.opts.NumberRegisters {
+		z.nReg[adj] = f
+	}
+
+	vb := meta.ViewBox
+	w, h := vb.Max[0]-vb.Min[0], vb.Max[1]-vb.Min[1]
+	if w <= 0 || h <= 0 {
+		w, h = 1, 1
+	}
+	rw, rh := float32(z.rect.Dx()), float32(z.rect.Dy())
+	sx, sy := rw/w, rh/h
+	z.m = f32.Aff3{
+		sx, 0, float32(z.rect.Min.X) - vb.Min[0]*sx,
+		0, sy, float32(z.rect.Min.Y) - vb.Min[1]*sy,
+	}
+}
+
+func (z *rasterizer) apply(x, y float32) (float32, float32) {
+	return z.m[0]*x + z.m[1]*y + z.m[2], z.m[3]*x + z.m[4]*y + z.m[5]
+}
+
+func (z *rasterizer) SetCReg(adj uint8, incremental bool, c Color) {
+	rgba := color.RGBA(c)
+	if incremental {
+		prev := z.cReg[adj]
+		rgba = color.RGBA{
+			R: prev.R + rgba.R,
+			G: prev.G + rgba.G,
+			B: prev.B + rgba.B,
+			A: prev.A + rgba.A,
+		}
+	}
+	if o, ok := z.opts.ColorRegisters[adj]; ok {
+		rgba = o
+	}
+	z.cReg[adj] = rgba
+}
+
+func (z *rasterizer) SetNReg(adj uint8, incremental bool, f float32) {
+	if incremental {
+		f += z.nReg[adj]
+	}
+	if o, ok := z.opts.NumberRegisters[adj]; ok {
+		f = o
+	}
+	z.nReg[adj] = f
+}
+
+func (z *rasterizer) StartPath(adj uint8, x, y float32) {
+	z.flushCurrent()
+	z.cur = subpath{fill: z.cReg[adj]}
+	dx, dy := z.apply(x, y)
+	z.cx, z.cy = dx, dy
+	z.cur.points = append(z.cur.points, f32.Vec2{dx, dy})
+	z.lastOp = 0
+}
+
+func (z *rasterizer) flushCurrent() {
+	if len(z.cur.points) > 1 {
+		z.subpaths = append(z.subpaths, z.cur)
+	}
+	z.cur = subpath{}
+}
+
+func (z *rasterizer) lineTo(x, y float32) {
+	z.cx, z.cy = x, y
+	z.cur.points = append(z.cur.points, f32.Vec2{x, y})
+	z.lastOp = 0
+}
+
+func (z *rasterizer) ClosePathEndPath() {
+	z.flushCurrent()
+}
+
+func (z *rasterizer) ClosePathAbsMoveTo(x, y float32) {
+	fill := z.cur.fill
+	z.flushCurrent()
+	z.cur = subpath{fill: fill}
+	dx, dy := z.apply(x, y)
+	z.cx, z.cy = dx, dy
+	z.cur.points = append(z.cur.points, f32.Vec2{dx, dy})
+	z.lastOp = 0
+}
+
+func (z *rasterizer) ClosePathRelMoveTo(x, y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	z.ClosePathAbsMoveTo(ix+x, iy+y)
+}
+
+// invert maps a device-space point back to icon vector space, so that the
+// "rel" ops (expressed in vector space) can be added to it.
+func (z *rasterizer) invert(dx, dy float32) (float32, float32) {
+	if z.m[0] == 0 || z.m[4] == 0 {
+		return 0, 0
+	}
+	return (dx - z.m[2]) / z.m[0], (dy - z.m[5]) / z.m[4]
+}
+
+func (z *rasterizer) AbsHLineTo(x float32) {
+	_, iy := z.invert(z.cx, z.cy)
+	dx, dy := z.apply(x, iy)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) RelHLineTo(x float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	dx, dy := z.apply(ix+x, iy)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) AbsVLineTo(y float32) {
+	ix, _ := z.invert(z.cx, z.cy)
+	dx, dy := z.apply(ix, y)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) RelVLineTo(y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	dx, dy := z.apply(ix, iy+y)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) AbsLineTo(x, y float32) {
+	dx, dy := z.apply(x, y)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) RelLineTo(x, y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	dx, dy := z.apply(ix+x, iy+y)
+	z.lineTo(dx, dy)
+}
+
+func (z *rasterizer) quadTo(x1, y1, x, y float32) {
+	x0, y0 := z.invert(z.cx, z.cy)
+	const n = 16
+	for i := 1; i <= n; i++ {
+		t := float32(i) / n
+		mt := 1 - t
+		px := mt*mt*x0 + 2*mt*t*x1 + t*t*x
+		py := mt*mt*y0 + 2*mt*t*y1 + t*t*y
+		dx, dy := z.apply(px, py)
+		z.lineTo(dx, dy)
+	}
+	z.lastQCtrl = f32.Vec2{x1, y1}
+	z.lastOp = 'Q'
+}
+
+func (z *rasterizer) cubeTo(x1, y1, x2, y2, x, y float32) {
+	x0, y0 := z.invert(z.cx, z.cy)
+	const n = 24
+	for i := 1; i <= n; i++ {
+		t := float32(i) / n
+		mt := 1 - t
+		px := mt*mt*mt*x0 + 3*mt*mt*t*x1 + 3*mt*t*t*x2 + t*t*t*x
+		py := mt*mt*mt*y0 + 3*mt*mt*t*y1 + 3*mt*t*t*y2 + t*t*t*y
+		dx, dy := z.apply(px, py)
+		z.lineTo(dx, dy)
+	}
+	z.lastCCtrl = f32.Vec2{x2, y2}
+	z.lastOp = 'C'
+}
+
+// reflectedQCtrl returns the implicit first control point of a smooth
+// quadratic (T) curve: the previous Q or T control point reflected across
+// the current point, or the current point itself if the previous op
+// wasn't a quadratic curve.
+func (z *rasterizer) reflectedQCtrl() (float32, float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	if z.lastOp != 'Q' {
+		return ix, iy
+	}
+	return 2*ix - z.lastQCtrl[0], 2*iy - z.lastQCtrl[1]
+}
+
+// reflectedCCtrl is reflectedQCtrl's cubic (S) equivalent.
+func (z *rasterizer) reflectedCCtrl() (float32, float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	if z.lastOp != 'C' {
+		return ix, iy
+	}
+	return 2*ix - z.lastCCtrl[0], 2*iy - z.lastCCtrl[1]
+}
+
+func (z *rasterizer) AbsQuadTo(x1, y1, x, y float32) { z.quadTo(x1, y1, x, y) }
+func (z *rasterizer) RelQuadTo(x1, y1, x, y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	z.quadTo(ix+x1, iy+y1, ix+x, iy+y)
+}
+func (z *rasterizer) AbsSmoothQuadTo(x, y float32) {
+	cx, cy := z.reflectedQCtrl()
+	z.quadTo(cx, cy, x, y)
+}
+func (z *rasterizer) RelSmoothQuadTo(x, y float32) {
+	cx, cy := z.reflectedQCtrl()
+	ix, iy := z.invert(z.cx, z.cy)
+	z.quadTo(cx, cy, ix+x, iy+y)
+}
+
+func (z *rasterizer) AbsCubeTo(x1, y1, x2, y2, x, y float32) { z.cubeTo(x1, y1, x2, y2, x, y) }
+func (z *rasterizer) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	z.cubeTo(ix+x1, iy+y1, ix+x2, iy+y2, ix+x, iy+y)
+}
+func (z *rasterizer) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	cx, cy := z.reflectedCCtrl()
+	z.cubeTo(cx, cy, x2, y2, x, y)
+}
+func (z *rasterizer) RelSmoothCubeTo(x2, y2, x, y float32) {
+	cx, cy := z.reflectedCCtrl()
+	ix, iy := z.invert(z.cx, z.cy)
+	z.cubeTo(cx, cy, ix+x2, iy+y2, ix+x, iy+y)
+}
+
+// arcTo flattens an elliptical arc into line segments using the SVG
+// endpoint-to-center parameterization.
+func (z *rasterizer) arcTo(rx, ry, phiDeg float32, largeArc, sweep bool, x, y float32) {
+	x0, y0 := z.invert(z.cx, z.cy)
+	if rx == 0 || ry == 0 {
+		dx, dy := z.apply(x, y)
+		z.lineTo(dx, dy)
+		return
+	}
+	phi := float64(phiDeg) * math.Pi / 180
+	sinPhi, cosPhi := math.Sin(phi), math.Cos(phi)
+
+	dx2, dy2 := float64(x0-x)/2, float64(y0-y)/2
+	x1p := cosPhi*dx2 + sinPhi*dy2
+	y1p := -sinPhi*dx2 + cosPhi*dy2
+
+	rxf, ryf := math.Abs(float64(rx)), math.Abs(float64(ry))
+	lambda := (x1p*x1p)/(rxf*rxf) + (y1p*y1p)/(ryf*ryf)
+	if lambda > 1 {
+		s := math.Sqrt(lambda)
+		rxf, ryf = rxf*s, ryf*s
+	}
+
+	sign := 1.0
+	if largeArc == sweep {
+		sign = -1
+	}
+	num := rxf*rxf*ryf*ryf - rxf*rxf*y1p*y1p - ryf*ryf*x1p*x1p
+	den := rxf*rxf*y1p*y1p + ryf*ryf*x1p*x1p
+	co := 0.0
+	if den != 0 && num > 0 {
+		co = sign * math.Sqrt(num/den)
+	}
+	cxp := co * (rxf * y1p / ryf)
+	cyp := co * -(ryf * x1p / rxf)
+
+	cx := cosPhi*cxp - sinPhi*cyp + float64(x0+x)/2
+	cy := sinPhi*cxp + cosPhi*cyp + float64(y0+y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		dot := ux*vx + uy*vy
+		lenProd := math.Hypot(ux, uy) * math.Hypot(vx, vy)
+		a := math.Acos(clamp(dot/lenProd, -1, 1))
+		if ux*vy-uy*vx < 0 {
+			a = -a
+		}
+		return a
+	}
+	theta1 := angle(1, 0, (x1p-cxp)/rxf, (y1p-cyp)/ryf)
+	dTheta := angle((x1p-cxp)/rxf, (y1p-cyp)/ryf, (-x1p-cxp)/rxf, (-y1p-cyp)/ryf)
+	if !sweep && dTheta > 0 {
+		dTheta -= 2 * math.Pi
+	} else if sweep && dTheta < 0 {
+		dTheta += 2 * math.Pi
+	}
+
+	const n = 32
+	for i := 1; i <= n; i++ {
+		t := theta1 + dTheta*float64(i)/n
+		px := cx + rxf*math.Cos(t)*cosPhi - ryf*math.Sin(t)*sinPhi
+		py := cy + rxf*math.Cos(t)*sinPhi + ryf*math.Sin(t)*cosPhi
+		ddx, ddy := z.apply(float32(px), float32(py))
+		z.lineTo(ddx, ddy)
+	}
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func (z *rasterizer) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	z.arcTo(rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+func (z *rasterizer) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	ix, iy := z.invert(z.cx, z.cy)
+	z.arcTo(rx, ry, xAxisRotation, largeArc, sweep, ix+x, iy+y)
+}
+
+// done fills every accumulated subpath into z.dst and is called once
+// decoding finishes; Decode itself has no explicit "end of icon" callback,
+// so Rasterize calls it after Decode returns.
+func (z *rasterizer) done() {
+	z.flushCurrent()
+	n := z.opts.AAKind.samplesPerAxis()
+	for _, sp := range z.subpaths {
+		fillSubpath(z.dst, z.rect, sp, n, z.opts.AAKind == AAGammaCorrect)
+	}
+}
+
+// fillSubpath rasterizes a single closed polygon with the nonzero winding
+// rule, sampling an n x n grid per pixel for anti-aliasing (n == 1 means
+// no anti-aliasing: a single sample at the pixel center).
+func fillSubpath(dst draw.Image, r image.Rectangle, sp subpath, n int, gammaCorrect bool) {
+	if len(sp.points) < 3 {
+		return
+	}
+	minY, maxY := sp.points[0][1], sp.points[0][1]
+	minX, maxX := sp.points[0][0], sp.points[0][0]
+	for _, p := range sp.points {
+		minX, maxX = math32Min(minX, p[0]), math32Max(maxX, p[0])
+		minY, maxY = math32Min(minY, p[1]), math32Max(maxY, p[1])
+	}
+	bounds := image.Rect(
+		maxInt(r.Min.X, int(math.Floor(float64(minX)))),
+		maxInt(r.Min.Y, int(math.Floor(float64(minY)))),
+		minInt(r.Max.X, int(math.Ceil(float64(maxX)))+1),
+		minInt(r.Max.Y, int(math.Ceil(float64(maxY)))+1),
+	).Intersect(r)
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			covered := 0
+			for sy := 0; sy < n; sy++ {
+				for sx := 0; sx < n; sx++ {
+					x := float32(px) + (float32(sx)+0.5)/float32(n)
+					y := float32(py) + (float32(sy)+0.5)/float32(n)
+					if windingNonZero(sp.points, x, y) {
+						covered++
+					}
+				}
+			}
+			if covered == 0 {
+				continue
+			}
+			coverage := float32(covered) / float32(n*n)
+			blendPixel(dst, px, py, sp.fill, coverage, gammaCorrect)
+		}
+	}
+}
+
+// windingNonZero reports whether (x, y) is inside the polygon described by
+// pts (implicitly closed), using the nonzero winding rule.
+func windingNonZero(pts []f32.Vec2, x, y float32) bool {
+	winding := 0
+	for i := range pts {
+		a := pts[i]
+		b := pts[(i+1)%len(pts)]
+		if a[1] <= y {
+			if b[1] > y && isLeft(a, b, x, y) > 0 {
+				winding++
+			}
+		} else {
+			if b[1] <= y && isLeft(a, b, x, y) < 0 {
+				winding--
+			}
+		}
+	}
+	return winding != 0
+}
+
+func isLeft(a, b f32.Vec2, x, y float32) float32 {
+	return (b[0]-a[0])*(y-a[1]) - (x-a[0])*(b[1]-a[1])
+}
+
+func blendPixel(dst draw.Image, x, y int, fill color.RGBA, coverage float32, gammaCorrect bool) {
+	if fill.A == 0 || coverage <= 0 {
+		return
+	}
+	a := coverage * float32(fill.A) / 0xff
+	if !gammaCorrect {
+		bg := dst.At(x, y)
+		br, bgc, bb, _ := bg.RGBA()
+		out := color.RGBA64{
+			R: lerp16(uint16(br), uint16(fill.R)<<8|uint16(fill.R), a),
+			G: lerp16(uint16(bgc), uint16(fill.G)<<8|uint16(fill.G), a),
+			B: lerp16(uint16(bb), uint16(fill.B)<<8|uint16(fill.B), a),
+			A: 0xffff,
+		}
+		dst.Set(x, y, out)
+		return
+	}
+	bg := dst.At(x, y)
+	br, bgc, bb, _ := bg.RGBA()
+	out := color.RGBA64{
+		R: toSRGB16(lerp(toLinear16(uint16(br)), toLinear16(uint16(fill.R)<<8|uint16(fill.R)), a)),
+		G: toSRGB16(lerp(toLinear16(uint16(bgc)), toLinear16(uint16(fill.G)<<8|uint16(fill.G)), a)),
+		B: toSRGB16(lerp(toLinear16(uint16(bb)), toLinear16(uint16(fill.B)<<8|uint16(fill.B)), a)),
+		A: 0xffff,
+	}
+	dst.Set(x, y, out)
+}
+
+func lerp16(a, b uint16, t float32) uint16 {
+	return uint16(float32(a)*(1-t) + float32(b)*t)
+}
+
+func lerp(a, b, t float32) float32 { return a*(1-t) + b*t }
+
+func toLinear16(v uint16) float32 {
+	c := float32(v) / 0xffff
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return float32(math.Pow(float64((c+0.055)/1.055), 2.4))
+}
+
+func toSRGB16(c float32) uint16 {
+	if c <= 0.0031308 {
+		c = c * 12.92
+	} else {
+		c = float32(1.055*math.Pow(float64(c), 1/2.4) - 0.055)
+	}
+	if c < 0 {
+		c = 0
+	}
+	if c > 1 {
+		c = 1
+	}
+	return uint16(c * 0xffff)
+}
+
+func math32Min(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func math32Max(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,392 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// PathOp is a single parsed SVG path data command. Verb is the command
+// letter as it appeared in the source ('M', 'l', 'C', 'a', 'z', etc.);
+// uppercase means absolute, lowercase means relative, matching the SVG
+// path data grammar. Args holds the command's numeric arguments, in
+// source order; unused trailing slots are zero. The elliptical arc
+// command needs the most slots: rx, ry, x-axis-rotation, large-arc-flag,
+// sweep-flag, x, y.
+type PathOp struct {
+	Verb byte
+	Args [7]float32
+}
+
+func (op PathOp) relative() bool { return 'a' <= op.Verb && op.Verb <= 'z' }
+
+// numArgs reports how many of Args are meaningful for the given verb.
+func numArgs(verb byte) int {
+	switch verb {
+	case 'L', 'l', 'T', 't':
+		return 2
+	case 'Q', 'q', 'S', 's':
+		return 4
+	case 'C', 'c':
+		return 6
+	case 'H', 'h', 'V', 'v':
+		return 1
+	case 'M', 'm':
+		return 2
+	case 'A', 'a':
+		return 7
+	case 'Z', 'z':
+		return 0
+	}
+	return -1
+}
+
+// ParsePathData parses the value of an SVG <path d="..."> (or equivalent)
+// attribute into a sequence of path ops, in the same absolute/relative form
+// as the source. It understands the full set of SVG path commands,
+// including the elliptical arc ('A'/'a').
+func ParsePathData(d string) ([]PathOp, error) {
+	d = strings.TrimSuffix(strings.TrimSpace(d), "z")
+	d = strings.TrimSuffix(d, "Z")
+	r := strings.NewReader(d)
+
+	var ops []PathOp
+	op := byte(0)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+
+		switch {
+		case b == ' ' || b == ',' || b == '\t' || b == '\n' || b == '\r':
+			continue
+		case 'A' <= b && b <= 'Z':
+			op = b
+		case 'a' <= b && b <= 'z':
+			op = b
+		default:
+			r.UnreadByte()
+		}
+
+		n := numArgs(op)
+		if n < 0 {
+			return nil, fmt.Errorf("svg: unknown path opcode %q", op)
+		}
+
+		var args [7]float32
+		if op == 'A' || op == 'a' {
+			if err := scanArc(&args, r); err != nil {
+				return nil, err
+			}
+		} else if err := scan(&args, r, n); err != nil {
+			return nil, err
+		}
+		ops = append(ops, PathOp{Verb: op, Args: args})
+	}
+	return ops, nil
+}
+
+// scan reads n comma-or-whitespace separated floats from r into args.
+func scan(args *[7]float32, r *strings.Reader, n int) error {
+	for i := 0; i < n; i++ {
+		skipSep(r)
+		f, err := scanFloat(r)
+		if err != nil {
+			return err
+		}
+		args[i] = f
+	}
+	return nil
+}
+
+// scanArc reads the seven arguments of an elliptical arc command: rx, ry,
+// x-axis-rotation, large-arc-flag, sweep-flag, x, y. The two flags are a
+// single '0' or '1' digit each, and SVG allows them to run together with no
+// separator (e.g. "a5 5 0 015 5" is rx=5 ry=5 rot=0 large=0 sweep=1 x=5
+// y=5), so they can't be scanned with the general-purpose float scanner.
+func scanArc(args *[7]float32, r *strings.Reader) error {
+	for i := 0; i < 3; i++ {
+		skipSep(r)
+		f, err := scanFloat(r)
+		if err != nil {
+			return err
+		}
+		args[i] = f
+	}
+	for i := 3; i < 5; i++ {
+		skipSep(r)
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != '0' && b != '1' {
+			return fmt.Errorf("svg: bad arc flag %q", b)
+		}
+		args[i] = float32(b - '0')
+	}
+	for i := 5; i < 7; i++ {
+		skipSep(r)
+		f, err := scanFloat(r)
+		if err != nil {
+			return err
+		}
+		args[i] = f
+	}
+	return nil
+}
+
+func skipSep(r *strings.Reader) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if b != ' ' && b != ',' && b != '\t' && b != '\n' && b != '\r' {
+			r.UnreadByte()
+			return
+		}
+	}
+}
+
+// scanFloat reads a single SVG number. Unlike fmt.Fscanf("%f"), it stops at
+// the start of the next number even when there is no separator between
+// them, e.g. the "1.2-3.4" in "l1.2-3.4" is two numbers, 1.2 and -3.4, not
+// a parse error.
+func scanFloat(r *strings.Reader) (float32, error) {
+	var buf []byte
+	if b, err := r.ReadByte(); err == nil {
+		if b == '+' || b == '-' {
+			buf = append(buf, b)
+		} else {
+			r.UnreadByte()
+		}
+	}
+	sawDigit, sawDot := false, false
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		switch {
+		case '0' <= b && b <= '9':
+			sawDigit = true
+			buf = append(buf, b)
+		case b == '.' && !sawDot:
+			sawDot = true
+			buf = append(buf, b)
+		case (b == 'e' || b == 'E') && sawDigit:
+			buf = append(buf, b)
+			if b2, err := r.ReadByte(); err == nil {
+				if b2 == '+' || b2 == '-' {
+					buf = append(buf, b2)
+				} else {
+					r.UnreadByte()
+				}
+			}
+		default:
+			r.UnreadByte()
+			goto done
+		}
+	}
+done:
+	if !sawDigit {
+		return 0, fmt.Errorf("svg: expected a number, found %q", string(buf))
+	}
+	f, err := atof(buf)
+	return f, err
+}
+
+// Matrix is a 2-D affine transform, stored as the six SVG matrix(a, b, c,
+// d, e, f) coefficients: a new point (x', y') is computed from (x, y) as
+//
+//	x' = a*x + c*y + e
+//	y' = b*x + d*y + f
+type Matrix [6]float32
+
+// Identity is the identity transform.
+var Identity = Matrix{1, 0, 0, 1, 0, 0}
+
+// Translate returns the matrix that translates by (tx, ty).
+func Translate(tx, ty float32) Matrix { return Matrix{1, 0, 0, 1, tx, ty} }
+
+// Scale returns the matrix that scales by (sx, sy).
+func Scale(sx, sy float32) Matrix { return Matrix{sx, 0, 0, sy, 0, 0} }
+
+// axisAligned reports whether m maps axis-aligned lines to axis-aligned
+// lines, i.e. whether it has no rotation or skew component.
+func (m Matrix) axisAligned() bool {
+	return m[1] == 0 && m[2] == 0
+}
+
+// rotationDeg returns the angle, in degrees, that m rotates the x-axis by.
+// For compositions of translations, rotations and uniform scales it's
+// exactly the rotation an arc's ellipse undergoes. When m includes a
+// non-uniform scale or a skew, the transformed ellipse's axes are not in
+// general rotated by this angle, and the arc TransformPath produces from it
+// only approximates the transformed arc.
+func (m Matrix) rotationDeg() float32 {
+	return float32(math.Atan2(float64(m[1]), float64(m[0]))) * 180 / math.Pi
+}
+
+// reflects reports whether m mirrors the plane (negative determinant),
+// which reverses the apparent sweep direction of anything drawn under it.
+func (m Matrix) reflects() bool {
+	return m[0]*m[3]-m[1]*m[2] < 0
+}
+
+// Mul returns the matrix equivalent to applying m and then n.
+func (m Matrix) Mul(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// Apply transforms the point (x, y).
+func (m Matrix) Apply(x, y float32) (float32, float32) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// ApplyVector transforms the vector (dx, dy), ignoring translation.
+func (m Matrix) ApplyVector(dx, dy float32) (float32, float32) {
+	return m[0]*dx + m[2]*dy, m[1]*dx + m[3]*dy
+}
+
+// TransformPath returns ops with m applied to every coordinate. The
+// returned ops are all absolute: relative commands are resolved against a
+// running current point before the matrix is applied, since a relative
+// displacement under a non-identity matrix is no longer simply "add this
+// vector" once translation is folded in.
+//
+// H and V (axis-aligned line-to) ops are preserved as-is when m has no
+// rotation or skew component, and rewritten as L otherwise, since a
+// rotated horizontal or vertical line is no longer axis-aligned.
+func TransformPath(ops []PathOp, m Matrix) []PathOp {
+	out := make([]PathOp, 0, len(ops))
+	var cx, cy float32 // current point, in source space
+	var sx, sy float32 // start-of-subpath point, in source space
+	aligned := m.axisAligned()
+	for _, op := range ops {
+		abs := op
+		switch op.Verb {
+		case 'M', 'm':
+			x, y := op.Args[0], op.Args[1]
+			if op.Verb == 'm' {
+				x, y = cx+x, cy+y
+			}
+			cx, cy = x, y
+			sx, sy = x, y
+			abs.Verb = 'M'
+			abs.Args[0], abs.Args[1] = m.Apply(x, y)
+		case 'L', 'l':
+			x, y := op.Args[0], op.Args[1]
+			if op.Verb == 'l' {
+				x, y = cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'L'
+			abs.Args[0], abs.Args[1] = m.Apply(x, y)
+		case 'H', 'h':
+			x := op.Args[0]
+			if op.Verb == 'h' {
+				x = cx + x
+			}
+			cx = x
+			if aligned {
+				abs.Verb = 'H'
+				abs.Args[0], _ = m.Apply(x, cy)
+			} else {
+				abs.Verb = 'L'
+				abs.Args[0], abs.Args[1] = m.Apply(x, cy)
+			}
+		case 'V', 'v':
+			y := op.Args[0]
+			if op.Verb == 'v' {
+				y = cy + y
+			}
+			cy = y
+			if aligned {
+				abs.Verb = 'V'
+				_, abs.Args[0] = m.Apply(cx, y)
+			} else {
+				abs.Verb = 'L'
+				abs.Args[0], abs.Args[1] = m.Apply(cx, y)
+			}
+		case 'C', 'c':
+			x1, y1, x2, y2, x, y := op.Args[0], op.Args[1], op.Args[2], op.Args[3], op.Args[4], op.Args[5]
+			if op.Verb == 'c' {
+				x1, y1, x2, y2, x, y = cx+x1, cy+y1, cx+x2, cy+y2, cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'C'
+			abs.Args[0], abs.Args[1] = m.Apply(x1, y1)
+			abs.Args[2], abs.Args[3] = m.Apply(x2, y2)
+			abs.Args[4], abs.Args[5] = m.Apply(x, y)
+		case 'S', 's':
+			x2, y2, x, y := op.Args[0], op.Args[1], op.Args[2], op.Args[3]
+			if op.Verb == 's' {
+				x2, y2, x, y = cx+x2, cy+y2, cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'S'
+			abs.Args[0], abs.Args[1] = m.Apply(x2, y2)
+			abs.Args[2], abs.Args[3] = m.Apply(x, y)
+		case 'Q', 'q':
+			x1, y1, x, y := op.Args[0], op.Args[1], op.Args[2], op.Args[3]
+			if op.Verb == 'q' {
+				x1, y1, x, y = cx+x1, cy+y1, cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'Q'
+			abs.Args[0], abs.Args[1] = m.Apply(x1, y1)
+			abs.Args[2], abs.Args[3] = m.Apply(x, y)
+		case 'T', 't':
+			x, y := op.Args[0], op.Args[1]
+			if op.Verb == 't' {
+				x, y = cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'T'
+			abs.Args[0], abs.Args[1] = m.Apply(x, y)
+		case 'A', 'a':
+			x, y := op.Args[5], op.Args[6]
+			if op.Verb == 'a' {
+				x, y = cx+x, cy+y
+			}
+			cx, cy = x, y
+			abs.Verb = 'A'
+			rxx, rxy := m.ApplyVector(op.Args[0], 0)
+			ryx, ryy := m.ApplyVector(0, op.Args[1])
+			abs.Args[0] = float32(math.Hypot(float64(rxx), float64(rxy)))
+			abs.Args[1] = float32(math.Hypot(float64(ryx), float64(ryy)))
+			abs.Args[2] = op.Args[2] + m.rotationDeg()
+			abs.Args[3] = op.Args[3]
+			abs.Args[4] = op.Args[4]
+			if m.reflects() {
+				// Mirroring the plane reverses the arc's apparent
+				// clockwise/counterclockwise direction, so the sweep flag
+				// must flip along with it.
+				if abs.Args[4] == 0 {
+					abs.Args[4] = 1
+				} else {
+					abs.Args[4] = 0
+				}
+			}
+			abs.Args[5], abs.Args[6] = m.Apply(x, y)
+		case 'Z', 'z':
+			cx, cy = sx, sy
+			abs.Verb = 'Z'
+		}
+		out = append(out, abs)
+	}
+	return out
+}
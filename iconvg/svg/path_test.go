@@ -0,0 +1,96 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"math"
+	"testing"
+)
+
+func closeEnough(a, b, eps float32) bool {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	return d <= eps
+}
+
+// TestTransformPathArcRotation checks that rotating an elliptical arc
+// preserves its radii (it must only add to the arc's own x-axis-rotation)
+// instead of collapsing them, which is what naively taking one component
+// of each transformed basis vector did.
+func TestTransformPathArcRotation(t *testing.T) {
+	ops, err := ParsePathData("M0 0 A10 5 0 0 1 10 0")
+	if err != nil {
+		t.Fatalf("ParsePathData: %v", err)
+	}
+
+	rot, err := ParseTransform("rotate(90)")
+	if err != nil {
+		t.Fatalf("ParseTransform: %v", err)
+	}
+
+	out := TransformPath(ops, rot)
+	var arc PathOp
+	for _, op := range out {
+		if op.Verb == 'A' {
+			arc = op
+		}
+	}
+	if arc.Verb != 'A' {
+		t.Fatal("no arc op in TransformPath output")
+	}
+
+	if !closeEnough(arc.Args[0], 10, 1e-3) || !closeEnough(arc.Args[1], 5, 1e-3) {
+		t.Errorf("rx, ry = %v, %v; want 10, 5 (radii must survive a pure rotation)", arc.Args[0], arc.Args[1])
+	}
+	if !closeEnough(arc.Args[2], 90, 1e-3) {
+		t.Errorf("x-axis-rotation = %v; want 90", arc.Args[2])
+	}
+}
+
+// TestTransformPathArcReflection checks that mirroring the plane (a
+// negative-determinant transform) flips the arc's sweep flag, since the
+// apparent clockwise/counterclockwise direction is reversed along with
+// everything else.
+func TestTransformPathArcReflection(t *testing.T) {
+	ops, err := ParsePathData("M0 0 A10 5 0 0 1 10 0")
+	if err != nil {
+		t.Fatalf("ParsePathData: %v", err)
+	}
+
+	mirror := Scale(-1, 1)
+	out := TransformPath(ops, mirror)
+	var arc PathOp
+	for _, op := range out {
+		if op.Verb == 'A' {
+			arc = op
+		}
+	}
+	if arc.Verb != 'A' {
+		t.Fatal("no arc op in TransformPath output")
+	}
+	if arc.Args[4] != 0 {
+		t.Errorf("sweep flag = %v; want 0 (flipped from the source's 1)", arc.Args[4])
+	}
+}
+
+func TestMatrixRotationDegAndReflects(t *testing.T) {
+	rot, err := ParseTransform("rotate(30)")
+	if err != nil {
+		t.Fatalf("ParseTransform: %v", err)
+	}
+	if got := float64(rot.rotationDeg()); math.Abs(got-30) > 1e-3 {
+		t.Errorf("rotationDeg() = %v; want 30", got)
+	}
+	if rot.reflects() {
+		t.Errorf("a pure rotation must not reflect")
+	}
+
+	mirror := Scale(-1, 1)
+	if !mirror.reflects() {
+		t.Errorf("Scale(-1, 1) must reflect")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseInlineStyle parses an element's style="..." attribute, a
+// semicolon-separated list of "property: value" declarations, into a
+// computedStyle. Unrecognized properties are ignored.
+func parseInlineStyle(s string) computedStyle {
+	var cs computedStyle
+	for _, decl := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		applyDecl(&cs, strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return cs
+}
+
+func applyDecl(cs *computedStyle, k, v string) {
+	switch k {
+	case "fill":
+		cs.fill = v
+	case "opacity", "fill-opacity":
+		if f, err := strconv.ParseFloat(v, 32); err == nil {
+			cs.opacity, cs.opacitySet = float32(f), true
+		}
+	}
+}
+
+// parseStyleSheet parses the (very small, non-cascading) subset of CSS
+// found in an SVG <style> block: a sequence of "selector { decls }"
+// rules, where selector is a single tag name, ".class" or "#id" (no
+// combinators). Rules for the same selector merge, later declarations
+// winning; resolveStyle then applies an element's tag rule, its class rules
+// in the order its class attribute lists them, and its id rule, each over
+// the one before, whatever their order in the sheet. It's intended for
+// the simple per-icon stylesheets Material Design and similar icon sets
+// emit, not general CSS.
+func parseStyleSheet(css string) map[string]computedStyle {
+	rules := map[string]computedStyle{}
+	for {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			break
+		}
+		close := strings.IndexByte(css[open:], '}')
+		if close < 0 {
+			break
+		}
+		close += open
+
+		selectors := strings.Split(css[:open], ",")
+		body := css[open+1 : close]
+		cs := computedStyle{}
+		for _, decl := range strings.Split(body, ";") {
+			k, v, ok := strings.Cut(decl, ":")
+			if !ok {
+				continue
+			}
+			applyDecl(&cs, strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+		for _, sel := range selectors {
+			sel = strings.TrimSpace(sel)
+			if sel != "" {
+				rules[sel] = mergeStyle(rules[sel], cs)
+			}
+		}
+
+		css = css[close+1:]
+	}
+	return rules
+}
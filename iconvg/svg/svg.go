@@ -0,0 +1,621 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package svg converts a subset of SVG images to the IconVG format, at
+// runtime. It grew out of a build-time code generator
+// (materialdesign/icons/gen.go) that only understood the narrow subset of
+// SVG used by the Material Design icon set; this package generalizes that
+// conversion into a library that callers can use on arbitrary SVG input.
+//
+// It understands <path>, <rect>, <circle>, <ellipse>, <line>, <polygon>,
+// <polyline>, <g> groups with inherited transforms, "style" attributes and
+// <style> blocks, viewBox and preserveAspectRatio, linear and radial
+// gradient fills, and <use>/<defs> references. It does not attempt to
+// support the full SVG specification (filters, masks, clipping, text,
+// animation, and so on are out of scope).
+package svg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/glycerine/shiny/iconvg"
+	"golang.org/x/image/math/f32"
+)
+
+// outSize is the width and height, in ideal vector space, of the IconVG
+// graphic this package generates, regardless of the size of the input SVG.
+// It matches the convention used by the Material Design icon generator.
+const outSize = 48
+
+// Options controls the conversion from SVG to IconVG.
+type Options struct {
+	// Palette overrides the default 64-color IconVG palette. A zero value
+	// means iconvg.DefaultPalette.
+	Palette *iconvg.Palette
+}
+
+// FromSVGString converts the SVG document in s to IconVG-encoded bytes.
+func FromSVGString(s string) ([]byte, error) {
+	return FromSVG(strings.NewReader(s), nil)
+}
+
+// FromSVG converts the SVG document read from r to IconVG-encoded bytes.
+func FromSVG(r io.Reader, opts *Options) ([]byte, error) {
+	doc := &svgRoot{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("svg: could not parse SVG: %v", err)
+	}
+
+	vb, err := doc.viewBox()
+	if err != nil {
+		return nil, err
+	}
+	m := viewBoxTransform(vb, doc.PreserveAspectRatio)
+
+	c := &converter{
+		defs:       map[string]*Element{},
+		gradients:  map[string]*gradient{},
+		classRules: parseStyleSheet(doc.Style),
+	}
+	c.index(&doc.Element, Identity)
+
+	palette := iconvg.DefaultPalette
+	if opts != nil && opts.Palette != nil {
+		palette = *opts.Palette
+	}
+	var enc iconvg.Encoder
+	enc.Reset(iconvg.Metadata{
+		ViewBox: iconvg.Rectangle{
+			Min: f32.Vec2{-outSize / 2, -outSize / 2},
+			Max: f32.Vec2{+outSize / 2, +outSize / 2},
+		},
+		Palette: palette,
+	})
+
+	if err := c.renderChildren(&enc, &doc.Element, m, computedStyle{fill: "#000", opacity: 1, opacitySet: true}); err != nil {
+		return nil, err
+	}
+
+	return enc.Bytes()
+}
+
+// Element is the common shape of every SVG element this package
+// understands; most leaf elements only use a handful of its fields.
+//
+// svgRoot, the document element, embeds it anonymously, so the root's own
+// attributes and children are decoded through the same fields.
+type Element struct {
+	XMLName xml.Name
+	Id      string `xml:"id,attr"`
+
+	// Shared presentation attributes.
+	Fill        string   `xml:"fill,attr"`
+	FillOpacity *float32 `xml:"fill-opacity,attr"`
+	Opacity     *float32 `xml:"opacity,attr"`
+	Style       string   `xml:"style,attr"`
+	Class       string   `xml:"class,attr"`
+	Transform   string   `xml:"transform,attr"`
+
+	// <path>
+	D string `xml:"d,attr"`
+
+	// <circle>, <ellipse>
+	Cx float32 `xml:"cx,attr"`
+	Cy float32 `xml:"cy,attr"`
+	R  float32 `xml:"r,attr"`
+	Rx float32 `xml:"rx,attr"`
+	Ry float32 `xml:"ry,attr"`
+
+	// <rect>
+	X      float32 `xml:"x,attr"`
+	Y      float32 `xml:"y,attr"`
+	Width  float32 `xml:"width,attr"`
+	Height float32 `xml:"height,attr"`
+
+	// <line>
+	X1 float32 `xml:"x1,attr"`
+	Y1 float32 `xml:"y1,attr"`
+	X2 float32 `xml:"x2,attr"`
+	Y2 float32 `xml:"y2,attr"`
+
+	// <polygon>, <polyline>
+	Points string `xml:"points,attr"`
+
+	// <use>
+	Href  string `xml:"href,attr"`
+	XHref string `xml:"http://www.w3.org/1999/xlink href,attr"`
+
+	// <linearGradient>, <radialGradient> and their <stop> children
+	GradientUnits string   `xml:"gradientUnits,attr"`
+	Offset        string   `xml:"offset,attr"`
+	StopColor     string   `xml:"stop-color,attr"`
+	StopOpacity   *float32 `xml:"stop-opacity,attr"`
+
+	Children []Element `xml:",any"`
+}
+
+// svgRoot is the document element, <svg>.
+type svgRoot struct {
+	Element
+	Width               string `xml:"width,attr"`
+	Height              string `xml:"height,attr"`
+	ViewBox             string `xml:"viewBox,attr"`
+	PreserveAspectRatio string `xml:"preserveAspectRatio,attr"`
+	Style               string `xml:"style"`
+}
+
+func (doc *svgRoot) viewBox() (f32.Vec4, error) {
+	if doc.ViewBox == "" {
+		return f32.Vec4{0, 0, outSize, outSize}, nil
+	}
+	fs := splitNums(doc.ViewBox)
+	if len(fs) != 4 {
+		return f32.Vec4{}, fmt.Errorf("svg: bad viewBox %q", doc.ViewBox)
+	}
+	var v f32.Vec4
+	for i, s := range fs {
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return f32.Vec4{}, fmt.Errorf("svg: bad viewBox %q: %v", doc.ViewBox, err)
+		}
+		v[i] = float32(f)
+	}
+	return v, nil
+}
+
+// viewBoxTransform returns the matrix that maps the viewBox (min-x, min-y,
+// width, height) to this package's fixed outSize x outSize output square,
+// honoring preserveAspectRatio's "meet" (the default) uniform scaling and
+// alignment keywords. "none" stretches non-uniformly.
+func viewBoxTransform(vb f32.Vec4, par string) Matrix {
+	minX, minY, w, h := vb[0], vb[1], vb[2], vb[3]
+	if w == 0 || h == 0 {
+		return Identity
+	}
+	fields := strings.Fields(par)
+	align := "xMidYMid"
+	meet := true
+	for _, f := range fields {
+		switch f {
+		case "none":
+			align = "none"
+		case "meet", "slice":
+			meet = f == "meet"
+		default:
+			align = f
+		}
+	}
+
+	sx, sy := outSize/w, outSize/h
+	if align != "none" && meet {
+		s := sx
+		if sy < s {
+			s = sy
+		}
+		sx, sy = s, s
+	} else if align != "none" {
+		s := sx
+		if sy > s {
+			s = sy
+		}
+		sx, sy = s, s
+	}
+
+	tx, ty := -minX*sx, -minY*sy
+	extraX, extraY := outSize-w*sx, outSize-h*sy
+	if strings.Contains(align, "xMid") {
+		tx += extraX / 2
+	} else if strings.Contains(align, "xMax") {
+		tx += extraX
+	}
+	if strings.Contains(align, "YMid") {
+		ty += extraY / 2
+	} else if strings.Contains(align, "YMax") {
+		ty += extraY
+	}
+
+	return Matrix{sx, 0, 0, sy, tx - outSize/2, ty - outSize/2}
+}
+
+func splitNums(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r'
+	})
+	return fields
+}
+
+func atof(buf []byte) (float32, error) {
+	f, err := strconv.ParseFloat(string(buf), 32)
+	if err != nil {
+		return 0, fmt.Errorf("svg: could not parse %q as a number: %v", buf, err)
+	}
+	return float32(f), nil
+}
+
+// gradient is a flattened <linearGradient> or <radialGradient>: this
+// package doesn't render true gradients (IconVG has no continuous gradient
+// primitive across an arbitrary shape), it approximates one with a single
+// color and opacity, the unweighted averages of the gradient's stops, which
+// adjFor blends into a CREG register the same way it handles fill-opacity.
+type gradient struct {
+	colorHex string
+	opacity  float32
+}
+
+// converter holds one FromSVG conversion's lookup tables: elements by id,
+// flattened gradients by id, and the <style> block's rules by selector.
+type converter struct {
+	defs       map[string]*Element
+	gradients  map[string]*gradient
+	classRules map[string]computedStyle
+}
+
+// index walks the document once, recording every element with an id (for
+// <use> references) and flattening every gradient under its id. Elements
+// inside <defs> are indexed like any other; walk skips <defs> when
+// rendering.
+func (c *converter) index(e *Element, m Matrix) {
+	for i := range e.Children {
+		ch := &e.Children[i]
+		if ch.Id != "" {
+			c.defs[ch.Id] = ch
+		}
+		switch ch.XMLName.Local {
+		case "linearGradient", "radialGradient":
+			c.gradients[ch.Id] = flattenGradient(ch)
+		}
+		c.index(ch, m)
+	}
+}
+
+func flattenGradient(g *Element) *gradient {
+	type rgba struct{ r, g, b, a float32 }
+	var sum rgba
+	var n float32
+	for i := range g.Children {
+		stop := &g.Children[i]
+		if stop.XMLName.Local != "stop" {
+			continue
+		}
+		hex := stop.StopColor
+		if hex == "" {
+			hex = "#000"
+		}
+		r, gg, b, ok := parseHexColor(hex)
+		if !ok {
+			continue
+		}
+		a := float32(1)
+		if stop.StopOpacity != nil {
+			a = *stop.StopOpacity
+		}
+		sum.r += r
+		sum.g += gg
+		sum.b += b
+		sum.a += a
+		n++
+	}
+	if n == 0 {
+		return &gradient{colorHex: "#000", opacity: 1}
+	}
+	return &gradient{
+		colorHex: fmt.Sprintf("#%02x%02x%02x",
+			int(sum.r/n*255), int(sum.g/n*255), int(sum.b/n*255)),
+		opacity: sum.a / n,
+	}
+}
+
+func parseHexColor(s string) (r, g, b float32, ok bool) {
+	s = strings.TrimPrefix(s, "#")
+	var ri, gi, bi int64
+	var err error
+	switch len(s) {
+	case 3:
+		ri, err = strconv.ParseInt(s[0:1]+s[0:1], 16, 32)
+		if err == nil {
+			gi, err = strconv.ParseInt(s[1:2]+s[1:2], 16, 32)
+		}
+		if err == nil {
+			bi, err = strconv.ParseInt(s[2:3]+s[2:3], 16, 32)
+		}
+	case 6:
+		ri, err = strconv.ParseInt(s[0:2], 16, 32)
+		if err == nil {
+			gi, err = strconv.ParseInt(s[2:4], 16, 32)
+		}
+		if err == nil {
+			bi, err = strconv.ParseInt(s[4:6], 16, 32)
+		}
+	default:
+		return 0, 0, 0, false
+	}
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return float32(ri) / 255, float32(gi) / 255, float32(bi) / 255, true
+}
+
+// computedStyle is the subset of CSS/presentation attributes this package
+// tracks, inherited down the tree the way SVG "fill" and "opacity" are.
+type computedStyle struct {
+	fill    string
+	opacity float32
+	// opacitySet reports whether opacity was ever explicitly assigned, so
+	// that an explicit "opacity:0" isn't indistinguishable from "no
+	// opacity rule applies here" (the zero value).
+	opacitySet bool
+}
+
+func (c *converter) resolveStyle(e *Element, parent computedStyle) computedStyle {
+	s := parent
+	if rule, ok := c.classRules[e.XMLName.Local]; ok {
+		s = mergeStyle(s, rule)
+	}
+	for _, cls := range strings.Fields(e.Class) {
+		if rule, ok := c.classRules["."+cls]; ok {
+			s = mergeStyle(s, rule)
+		}
+	}
+	if e.Id != "" {
+		if rule, ok := c.classRules["#"+e.Id]; ok {
+			s = mergeStyle(s, rule)
+		}
+	}
+	if e.Style != "" {
+		s = mergeStyle(s, parseInlineStyle(e.Style))
+	}
+	if e.Fill != "" {
+		s.fill = e.Fill
+	}
+	if e.FillOpacity != nil {
+		s.opacity, s.opacitySet = *e.FillOpacity, true
+	}
+	if e.Opacity != nil {
+		s.opacity, s.opacitySet = *e.Opacity, true
+	}
+	return s
+}
+
+func mergeStyle(base, overlay computedStyle) computedStyle {
+	if overlay.fill != "" {
+		base.fill = overlay.fill
+	}
+	if overlay.opacitySet {
+		base.opacity, base.opacitySet = overlay.opacity, true
+	}
+	return base
+}
+
+// renderChildren walks e's children, resolving groups, references and
+// shapes, and emits IconVG path ops for each into enc.
+func (c *converter) renderChildren(enc *iconvg.Encoder, e *Element, m Matrix, style computedStyle) error {
+	adjs := map[color.RGBA]uint8{}
+	return c.walk(enc, e, m, style, adjs)
+}
+
+func (c *converter) walk(enc *iconvg.Encoder, e *Element, m Matrix, parentStyle computedStyle, adjs map[color.RGBA]uint8) error {
+	for i := range e.Children {
+		ch := &e.Children[i]
+		switch ch.XMLName.Local {
+		case "defs", "style", "linearGradient", "radialGradient":
+			continue
+		}
+
+		cm := m
+		if ch.Transform != "" {
+			t, err := ParseTransform(ch.Transform)
+			if err != nil {
+				return err
+			}
+			cm = t.Mul(m)
+		}
+		style := c.resolveStyle(ch, parentStyle)
+
+		switch ch.XMLName.Local {
+		case "g", "a":
+			if err := c.walk(enc, ch, cm, style, adjs); err != nil {
+				return err
+			}
+			continue
+		case "use":
+			href := ch.Href
+			if href == "" {
+				href = ch.XHref
+			}
+			href = strings.TrimPrefix(href, "#")
+			target, ok := c.defs[href]
+			if !ok {
+				return fmt.Errorf("svg: <use> references unknown id %q", href)
+			}
+			um := Translate(ch.X, ch.Y).Mul(cm)
+			wrapper := Element{XMLName: ch.XMLName, Children: []Element{*target}}
+			if err := c.walk(enc, &wrapper, um, style, adjs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ops, err := c.shapeOps(ch)
+		if err != nil {
+			return err
+		}
+		if ops == nil {
+			continue
+		}
+		ops = TransformPath(ops, cm)
+
+		adj, ok, err := c.adjFor(enc, style, adjs)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := emitPath(enc, ops, adj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adjFor returns the CREG adjustment index for style's effective fill
+// color, allocating and initializing a new one the first time a given
+// color is seen. adj 0 is never allocated this way: it's the palette's
+// own opaque black, reused as-is for the common monochrome-icon case.
+//
+// It reports ok=false for fill:none, meaning the shape isn't drawn at
+// all, and an error if doing so would need more than the 63 remaining
+// custom registers (numRegs - 1, since adj 0 is reserved).
+//
+// A gradient fill (url(#id)) is approximated by its flattened average
+// color, with its average stop opacity multiplied into the fill's own
+// opacity; IconVG has no continuous gradient
+// primitive, so per-pixel gradients aren't reproduced, only their overall
+// tint and alpha. An unrecognized fill (a named color or anything else
+// parseHexColor doesn't understand) falls back to black.
+func (c *converter) adjFor(enc *iconvg.Encoder, style computedStyle, adjs map[color.RGBA]uint8) (adj uint8, ok bool, err error) {
+	if style.fill == "none" {
+		return 0, false, nil
+	}
+	hex, opacity := style.fill, style.opacity
+	if grad, isGrad := c.gradients[strings.TrimSuffix(strings.TrimPrefix(hex, "url(#"), ")")]; isGrad {
+		hex, opacity = grad.colorHex, opacity*grad.opacity
+	}
+	r, g, b, parsed := parseHexColor(hex)
+	if !parsed {
+		r, g, b = 0, 0, 0
+	}
+	rgb := color.RGBA{R: uint8(r*0xff + 0.5), G: uint8(g*0xff + 0.5), B: uint8(b*0xff + 0.5), A: 0xff}
+	blended := color.RGBA(iconvg.BlendColor(uint8(opacity*0xff+0.5), color.RGBA{}, rgb))
+	if blended == (color.RGBA{A: 0xff}) {
+		return 0, true, nil
+	}
+	if adj, ok := adjs[blended]; ok {
+		return adj, true, nil
+	}
+	if len(adjs) >= 63 {
+		return 0, false, fmt.Errorf("svg: more than 63 distinct fill colors/opacities in one document")
+	}
+	adj = uint8(len(adjs) + 1)
+	adjs[blended] = adj
+	enc.SetCReg(adj, false, iconvg.Color(blended))
+	return adj, true, nil
+}
+
+// emitPath emits ops as a single IconVG path, starting it on the shape's
+// first 'M' and ending it with ClosePathEndPath once every op has been
+// emitted. Each call to emitPath is one shape: a shape's own compound
+// subpaths (a "d" attribute with more than one 'M') share the path via
+// ClosePathAbsMoveTo, but the path itself must not span across shapes, or
+// a later shape's fill/opacity (its adj) would never reach the encoder.
+func emitPath(enc *iconvg.Encoder, ops []PathOp, adj uint8) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	started := false
+	for i, op := range ops {
+		switch op.Verb {
+		case 'M':
+			if !started {
+				started = true
+				enc.StartPath(adj, op.Args[0], op.Args[1])
+			} else {
+				enc.ClosePathAbsMoveTo(op.Args[0], op.Args[1])
+			}
+		case 'L':
+			enc.AbsLineTo(op.Args[0], op.Args[1])
+		case 'H':
+			enc.AbsHLineTo(op.Args[0])
+		case 'V':
+			enc.AbsVLineTo(op.Args[0])
+		case 'C':
+			enc.AbsCubeTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3], op.Args[4], op.Args[5])
+		case 'S':
+			enc.AbsSmoothCubeTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3])
+		case 'Q':
+			enc.AbsQuadTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3])
+		case 'T':
+			enc.AbsSmoothQuadTo(op.Args[0], op.Args[1])
+		case 'A':
+			enc.AbsArcTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3] != 0, op.Args[4] != 0, op.Args[5], op.Args[6])
+		case 'Z':
+			if i != len(ops)-1 {
+				enc.ClosePathAbsMoveTo(ops[0].Args[0], ops[0].Args[1])
+			}
+		default:
+			return fmt.Errorf("svg: unsupported normalized opcode %q", op.Verb)
+		}
+	}
+	enc.ClosePathEndPath()
+	return nil
+}
+
+// shapeOps converts a leaf shape element (path, rect, circle, ellipse,
+// line, polygon, polyline) into path ops in the element's own local
+// coordinate space. It returns a nil slice (and nil error) for elements
+// that aren't renderable shapes.
+func (c *converter) shapeOps(e *Element) ([]PathOp, error) {
+	switch e.XMLName.Local {
+	case "path":
+		return ParsePathData(e.D)
+	case "rect":
+		x, y, w, h := e.X, e.Y, e.Width, e.Height
+		return []PathOp{
+			{Verb: 'M', Args: [7]float32{x, y}},
+			{Verb: 'H', Args: [7]float32{x + w}},
+			{Verb: 'V', Args: [7]float32{y + h}},
+			{Verb: 'H', Args: [7]float32{x}},
+			{Verb: 'Z'},
+		}, nil
+	case "line":
+		return []PathOp{
+			{Verb: 'M', Args: [7]float32{e.X1, e.Y1}},
+			{Verb: 'L', Args: [7]float32{e.X2, e.Y2}},
+		}, nil
+	case "circle":
+		return ellipseOps(e.Cx, e.Cy, e.R, e.R), nil
+	case "ellipse":
+		return ellipseOps(e.Cx, e.Cy, e.Rx, e.Ry), nil
+	case "polygon", "polyline":
+		fs := splitNums(e.Points)
+		if len(fs) < 4 || len(fs)%2 != 0 {
+			return nil, fmt.Errorf("svg: bad points %q", e.Points)
+		}
+		pts := make([]float32, len(fs))
+		for i, s := range fs {
+			f, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return nil, fmt.Errorf("svg: bad points %q: %v", e.Points, err)
+			}
+			pts[i] = float32(f)
+		}
+		ops := []PathOp{{Verb: 'M', Args: [7]float32{pts[0], pts[1]}}}
+		for i := 2; i+1 < len(pts); i += 2 {
+			ops = append(ops, PathOp{Verb: 'L', Args: [7]float32{pts[i], pts[i+1]}})
+		}
+		if e.XMLName.Local == "polygon" {
+			ops = append(ops, PathOp{Verb: 'Z'})
+		}
+		return ops, nil
+	}
+	return nil, nil
+}
+
+// ellipseOps converts a circle or ellipse to two 180-degree arcTo ops, as
+// an IconVG path has no ellipse primitive. The start and end points of a
+// single 360-degree arc would be coincident, which is degenerate, hence
+// two half-arcs.
+func ellipseOps(cx, cy, rx, ry float32) []PathOp {
+	return []PathOp{
+		{Verb: 'M', Args: [7]float32{cx - rx, cy}},
+		{Verb: 'A', Args: [7]float32{rx, ry, 0, 0, 1, cx + rx, cy}},
+		{Verb: 'A', Args: [7]float32{rx, ry, 0, 0, 1, cx - rx, cy}},
+		{Verb: 'Z'},
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseTransform parses an SVG transform attribute value, e.g.
+// "translate(10 20) rotate(45) scale(2)", into the single Matrix
+// equivalent to applying each listed transform in order.
+func ParseTransform(s string) (Matrix, error) {
+	m := Identity
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t\n\r,")
+		if s == "" {
+			break
+		}
+		open := strings.IndexByte(s, '(')
+		if open < 0 {
+			return Matrix{}, fmt.Errorf("svg: bad transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		close := strings.IndexByte(s[open:], ')')
+		if close < 0 {
+			return Matrix{}, fmt.Errorf("svg: bad transform %q", s)
+		}
+		close += open
+		args, err := parseFloatList(s[open+1 : close])
+		if err != nil {
+			return Matrix{}, fmt.Errorf("svg: bad transform %q: %v", s, err)
+		}
+
+		var t Matrix
+		switch name {
+		case "translate":
+			tx, ty := arg(args, 0), arg(args, 1)
+			t = Translate(tx, ty)
+		case "scale":
+			sx := arg(args, 0)
+			sy := sx
+			if len(args) > 1 {
+				sy = args[1]
+			}
+			t = Scale(sx, sy)
+		case "rotate":
+			if len(args) == 0 {
+				return Matrix{}, fmt.Errorf("svg: rotate() needs an angle")
+			}
+			rad := float64(args[0]) * math.Pi / 180
+			sin, cos := float32(math.Sin(rad)), float32(math.Cos(rad))
+			rot := Matrix{cos, sin, -sin, cos, 0, 0}
+			if len(args) >= 3 {
+				cx, cy := args[1], args[2]
+				t = Translate(-cx, -cy).Mul(rot).Mul(Translate(cx, cy))
+			} else {
+				t = rot
+			}
+		case "skewX":
+			if len(args) == 0 {
+				return Matrix{}, fmt.Errorf("svg: skewX() needs an angle")
+			}
+			t = Matrix{1, 0, float32(math.Tan(float64(args[0]) * math.Pi / 180)), 1, 0, 0}
+		case "skewY":
+			if len(args) == 0 {
+				return Matrix{}, fmt.Errorf("svg: skewY() needs an angle")
+			}
+			t = Matrix{1, float32(math.Tan(float64(args[0]) * math.Pi / 180)), 0, 1, 0, 0}
+		case "matrix":
+			if len(args) != 6 {
+				return Matrix{}, fmt.Errorf("svg: matrix() needs 6 args, got %d", len(args))
+			}
+			t = Matrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		default:
+			return Matrix{}, fmt.Errorf("svg: unknown transform %q", name)
+		}
+		// SVG composes a transform list left to right as matrix products in
+		// that same order (T1 T2 ...), which, because SVG transforms apply
+		// to column vectors, means the rightmost (last-parsed) function is
+		// applied to the point first. Our Matrix.Mul(a, b) composes in
+		// "apply a, then b" order for row vectors, so folding in a newly
+		// parsed function goes on the left: m = t.Mul(m).
+		m = t.Mul(m)
+		s = s[close+1:]
+	}
+	return m, nil
+}
+
+func arg(args []float32, i int) float32 {
+	if i < len(args) {
+		return args[i]
+	}
+	return 0
+}
+
+func parseFloatList(s string) ([]float32, error) {
+	fields := splitNums(s)
+	out := make([]float32, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = float32(v)
+	}
+	return out, nil
+}
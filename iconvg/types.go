@@ -0,0 +1,74 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iconvg
+
+import "image/color"
+
+// Rectangle is an axis-aligned rectangle in icon vector space.
+type Rectangle struct {
+	Min, Max [2]float32
+}
+
+// numRegs is the number of CREG/NREG registers an icon has to work with;
+// adj, the register index an op like SetCReg or StartPath carries, must
+// be in [0, numRegs).
+const numRegs = 64
+
+// Palette is the 64 colors an icon's CREG registers start out as, before
+// any SetCReg. There are no built-in colors beyond these.
+type Palette [numRegs]color.RGBA
+
+// DefaultPalette is the palette used when an icon doesn't specify its own.
+// Entry 0 is opaque black, as most Material Design icons are monochrome and
+// rely on the caller or the renderer to recolor them via CREG overrides;
+// every other entry is the zero (fully transparent) color.
+var DefaultPalette = Palette{
+	0: color.RGBA{A: 0xff},
+}
+
+// Color is an RGBA color that can be assigned to a CREG register via
+// SetCReg, either directly or as computed by BlendColor.
+type Color color.RGBA
+
+// Metadata is the information carried alongside an icon's path data:
+// its coordinate space, its color palette, and how it's meant to be
+// rendered.
+type Metadata struct {
+	ViewBox Rectangle
+	Palette Palette
+
+	// ColorSlots names the registers a caller is expected to recolor
+	// before drawing, e.g. a "fg" slot for an icon's foreground so it can
+	// be drawn in the current theme's text color. Slots not set here
+	// still render fine; they just can't be targeted by name.
+	ColorSlots []ColorSlot
+
+	// SuggestedViewport is the pixel size this icon was designed for,
+	// e.g. the 24x24 or 48x48dp grid Material Design icons are drawn on.
+	// It's a hint for picking a default rasterization size; it doesn't
+	// affect ViewBox or path coordinates.
+	SuggestedViewport Viewport
+
+	// RTLMirror reports whether this icon should be horizontally flipped
+	// when rendered in a right-to-left layout, as Material Design does
+	// for icons like "back" and "forward" whose meaning is directional.
+	RTLMirror bool
+}
+
+// ColorSlot is a named, themeable color register.
+type ColorSlot struct {
+	// Name identifies the slot, e.g. "fg", "accent", "disabled".
+	Name string
+	// Register is the CREG index this slot controls.
+	Register uint8
+	// Default is the color the slot holds until a caller overrides it
+	// (e.g. via RasterOptions.ColorRegisters).
+	Default color.RGBA
+}
+
+// Viewport is a suggested pixel size.
+type Viewport struct {
+	Width, Height int
+}
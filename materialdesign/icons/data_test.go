@@ -0,0 +1,12 @@
+// This is a minimal, hand-encoded fixture standing in for gen.go's real
+// output; see data.go.
+
+package icons
+
+var list = []struct {
+	name string
+	data []byte
+}{
+	{"ActionDone", ActionDone},
+	{"AvFiberManualRecord", AvFiberManualRecord},
+}
@@ -2,6 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build ignore
 // +build ignore
 
 package main
@@ -13,7 +14,7 @@ import (
 	"flag"
 	"fmt"
 	"go/format"
-	"io"
+	"image/color"
 	"io/ioutil"
 	"log"
 	"os"
@@ -24,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/glycerine/shiny/iconvg"
+	"github.com/glycerine/shiny/iconvg/svg"
 	"golang.org/x/image/math/f32"
 )
 
@@ -35,11 +37,23 @@ var mdicons = flag.String("mdicons", "", "The directory on the local file system
 // IconVG graphic, regardless of the size of the input SVG.
 const outSize = 48
 
+// colorSlots is the Metadata.ColorSlots given to every generated icon.
+// Material Design icons are monochrome: a fully opaque fill is drawn with
+// adj 0, which genPath never SetCReg's, so it keeps DefaultPalette's opaque
+// black, and a caller that wants to theme an icon's foreground can target
+// that same register by name via
+// iconvg.Icon.SetSlot("fg", ...).
+var colorSlots = []iconvg.ColorSlot{
+	{Name: "fg", Register: 0, Default: color.RGBA{A: 0xff}},
+}
+
 // errSkip deliberately skips generating an icon.
 //
 // When manually debugging one particular icon, it can be useful to add
 // something like:
-// 	if baseName != "check_box" { return errSkip }
+//
+//	if baseName != "check_box" { return errSkip }
+//
 // at the top of func genFile.
 var errSkip = errors.New("skipping SVG to IconVG conversion")
 
@@ -345,7 +359,10 @@ func genFile(fqSVGDirName, dirName, baseName, fileName string, size float32) err
 			Min: f32.Vec2{-24, -24},
 			Max: f32.Vec2{+24, +24},
 		},
-		Palette: iconvg.DefaultPalette,
+		Palette:           iconvg.DefaultPalette,
+		ColorSlots:        colorSlots,
+		SuggestedViewport: iconvg.Viewport{Width: int(size), Height: int(size)},
+		RTLMirror:         strings.HasSuffix(baseName, "_rtl"),
 	})
 
 	g := &SVG{}
@@ -421,9 +438,9 @@ func genPath(enc *iconvg.Encoder, p *Path, adjs map[float32]uint8, size float32,
 		if adj, ok = adjs[opacity]; !ok {
 			adj = uint8(len(adjs) + 1)
 			adjs[opacity] = adj
-			// Set CREG[0-adj] to be a blend of transparent (0x7f) and the
-			// first custom palette color (0x80).
-			enc.SetCReg(adj, false, iconvg.BlendColor(uint8(opacity*0xff), 0x7f, 0x80))
+			// Set CREG[0-adj] to be a blend of transparent and opaque
+			// black, the same scheme iconvg/svg uses for fill-opacity.
+			enc.SetCReg(adj, false, iconvg.BlendColor(uint8(opacity*0xff), color.RGBA{}, color.RGBA{A: 0xff}))
 		}
 	}
 
@@ -461,131 +478,50 @@ func genPath(enc *iconvg.Encoder, p *Path, adjs map[float32]uint8, size float32,
 	return nil
 }
 
+// genPathData parses an SVG path's "d" attribute using the iconvg/svg
+// package (which grew out of this very function) and re-emits it as
+// Encoder calls, scaled and translated from the source SVG's coordinate
+// space into this generator's fixed outSize x outSize vector space.
 func genPathData(enc *iconvg.Encoder, adj uint8, pathData string, size float32, offset f32.Vec2) error {
-	if strings.HasSuffix(pathData, "z") {
-		pathData = pathData[:len(pathData)-1]
+	ops, err := svg.ParsePathData(pathData)
+	if err != nil {
+		return err
 	}
-	r := strings.NewReader(pathData)
-
-	var args [6]float32
-	op, relative, started := byte(0), false, false
-	for {
-		b, err := r.ReadByte()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
 
-		switch {
-		case b == ' ':
-			continue
-		case 'A' <= b && b <= 'Z':
-			op, relative = b, false
-		case 'a' <= b && b <= 'z':
-			op, relative = b, true
-		default:
-			r.UnreadByte()
-		}
-
-		n := 0
-		switch op {
-		case 'L', 'l', 'T', 't':
-			n = 2
-		case 'Q', 'q', 'S', 's':
-			n = 4
-		case 'C', 'c':
-			n = 6
-		case 'H', 'h', 'V', 'v':
-			n = 1
-		case 'M', 'm':
-			n = 2
-		case 'Z', 'z':
-		default:
-			return fmt.Errorf("unknown opcode %c\n", b)
-		}
-
-		scan(&args, r, n)
-		normalize(&args, n, op, size, offset, relative)
-
-		switch op {
-		case 'L':
-			enc.AbsLineTo(args[0], args[1])
-		case 'l':
-			enc.RelLineTo(args[0], args[1])
-		case 'T':
-			enc.AbsSmoothQuadTo(args[0], args[1])
-		case 't':
-			enc.RelSmoothQuadTo(args[0], args[1])
-		case 'Q':
-			enc.AbsQuadTo(args[0], args[1], args[2], args[3])
-		case 'q':
-			enc.RelQuadTo(args[0], args[1], args[2], args[3])
-		case 'S':
-			enc.AbsSmoothCubeTo(args[0], args[1], args[2], args[3])
-		case 's':
-			enc.RelSmoothCubeTo(args[0], args[1], args[2], args[3])
-		case 'C':
-			enc.AbsCubeTo(args[0], args[1], args[2], args[3], args[4], args[5])
-		case 'c':
-			enc.RelCubeTo(args[0], args[1], args[2], args[3], args[4], args[5])
-		case 'H':
-			enc.AbsHLineTo(args[0])
-		case 'h':
-			enc.RelHLineTo(args[0])
-		case 'V':
-			enc.AbsVLineTo(args[0])
-		case 'v':
-			enc.RelVLineTo(args[0])
+	scale := outSize / size
+	m := svg.Scale(scale, scale).Mul(svg.Translate(-outSize/2-offset[0], -outSize/2-offset[1]))
+	started := false
+	for _, op := range svg.TransformPath(ops, m) {
+		switch op.Verb {
 		case 'M':
 			if !started {
 				started = true
-				enc.StartPath(adj, args[0], args[1])
+				enc.StartPath(adj, op.Args[0], op.Args[1])
 			} else {
-				enc.ClosePathAbsMoveTo(args[0], args[1])
+				enc.ClosePathAbsMoveTo(op.Args[0], op.Args[1])
 			}
-		case 'm':
-			enc.ClosePathRelMoveTo(args[0], args[1])
+		case 'L':
+			enc.AbsLineTo(op.Args[0], op.Args[1])
+		case 'H':
+			enc.AbsHLineTo(op.Args[0])
+		case 'V':
+			enc.AbsVLineTo(op.Args[0])
+		case 'C':
+			enc.AbsCubeTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3], op.Args[4], op.Args[5])
+		case 'S':
+			enc.AbsSmoothCubeTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3])
+		case 'Q':
+			enc.AbsQuadTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3])
+		case 'T':
+			enc.AbsSmoothQuadTo(op.Args[0], op.Args[1])
+		case 'A':
+			enc.AbsArcTo(op.Args[0], op.Args[1], op.Args[2], op.Args[3] != 0, op.Args[4] != 0, op.Args[5], op.Args[6])
+		case 'Z':
+			// A 'Z' is only ever the last op of a subpath; the next 'M'
+			// (as ClosePathAbsMoveTo) or genPath's ClosePathEndPath closes it.
+		default:
+			return fmt.Errorf("unexpected normalized opcode %q", op.Verb)
 		}
 	}
 	return nil
 }
-
-func scan(args *[6]float32, r *strings.Reader, n int) {
-	for i := 0; i < n; i++ {
-		for {
-			if b, _ := r.ReadByte(); b != ' ' {
-				r.UnreadByte()
-				break
-			}
-		}
-		fmt.Fscanf(r, "%f", &args[i])
-	}
-}
-
-func atof(s []byte) (float32, error) {
-	f, err := strconv.ParseFloat(string(s), 32)
-	if err != nil {
-		return 0, fmt.Errorf("could not parse %q as a float32: %v", s, err)
-	}
-	return float32(f), err
-}
-
-func normalize(args *[6]float32, n int, op byte, size float32, offset f32.Vec2, relative bool) {
-	for i := 0; i < n; i++ {
-		args[i] *= outSize / size
-		if relative {
-			continue
-		}
-		args[i] -= outSize / 2
-		switch {
-		case n != 1:
-			args[i] -= offset[i&0x01]
-		case op == 'H':
-			args[i] -= offset[0]
-		case op == 'V':
-			args[i] -= offset[1]
-		}
-	}
-}